@@ -1,15 +1,128 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/zk3151463/pake-go/pkg/builder"
 	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/favicon"
+	"github.com/zk3151463/pake-go/pkg/httpclient"
 	"github.com/zk3151463/pake-go/pkg/initializer"
+	"github.com/zk3151463/pake-go/pkg/manifest"
+	"github.com/zk3151463/pake-go/pkg/signing"
+	"github.com/zk3151463/pake-go/pkg/update"
 )
 
+// flagOverrides copies the value of an explicitly set flag from the flag
+// config into a config loaded from a file or manifest
+var flagOverrides = map[string]func(dst, src *config.Config){
+	"url":              func(dst, src *config.Config) { dst.URL = src.URL },
+	"name":             func(dst, src *config.Config) { dst.Name = src.Name },
+	"icon":             func(dst, src *config.Config) { dst.Icon = src.Icon },
+	"width":            func(dst, src *config.Config) { dst.Width = src.Width },
+	"height":           func(dst, src *config.Config) { dst.Height = src.Height },
+	"hide-title-bar":   func(dst, src *config.Config) { dst.HideTitleBar = src.HideTitleBar },
+	"transparent":      func(dst, src *config.Config) { dst.Transparent = src.Transparent },
+	"always-on-top":    func(dst, src *config.Config) { dst.AlwaysOnTop = src.AlwaysOnTop },
+	"user-agent":       func(dst, src *config.Config) { dst.UserAgent = src.UserAgent },
+	"window-mode":      func(dst, src *config.Config) { dst.WindowMode = src.WindowMode },
+	"proxy":            overrideProxy,
+	"proxy-bypass":     overrideProxy,
+	"proxy-pac":        overrideProxy,
+	"data-dir":         func(dst, src *config.Config) { dst.DataDir = src.DataDir },
+	"incognito":        func(dst, src *config.Config) { dst.Incognito = src.Incognito },
+	"theme":            func(dst, src *config.Config) { dst.Theme = src.Theme },
+	"background-color": func(dst, src *config.Config) { dst.BackgroundColor = src.BackgroundColor },
+	"dark-filter":      func(dst, src *config.Config) { dst.DarkFilter = src.DarkFilter },
+	"version":          func(dst, src *config.Config) { dst.Version = src.Version },
+	"description":      func(dst, src *config.Config) { dst.Description = src.Description },
+	"identifier":       func(dst, src *config.Config) { dst.Identifier = src.Identifier },
+	"company":          func(dst, src *config.Config) { dst.Company = src.Company },
+	"copyright":        func(dst, src *config.Config) { dst.Copyright = src.Copyright },
+	"author":           func(dst, src *config.Config) { dst.Author = src.Author },
+	"package":          overridePackage,
+	"platform":         func(dst, src *config.Config) { dst.Platforms = src.Platforms },
+	"assets":           func(dst, src *config.Config) { dst.Assets = src.Assets },
+	"templates-dir":    func(dst, src *config.Config) { dst.TemplatesDir = src.TemplatesDir },
+	"sign-key":         overrideSigningKey,
+}
+
+// resolveConfig loads the config file or starts from the flag config, and
+// applies the manifest over it. The flags named in set take precedence over
+// both.
+func resolveConfig(flagConfig *config.Config, configFile string, fromManifest string, set []string) (*config.Config, error) {
+	// Copy the flag config so the manifest does not overwrite the flag values
+	fc := *flagConfig
+	cfg := &fc
+
+	// If config file is provided, load it
+	if configFile != "" {
+		loadedConfig, err := config.LoadConfig(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %w", err)
+		}
+		cfg = loadedConfig
+	}
+
+	// If a manifest is provided, apply it over the config
+	if fromManifest != "" {
+		client := httpclient.New(cfg.Proxy)
+		m, err := manifest.Load(fromManifest, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load manifest: %w", err)
+		}
+		m.Apply(cfg, favicon.NewFinder(client, ""))
+	}
+
+	// Explicit flags take precedence over the config file and the manifest
+	if configFile != "" || fromManifest != "" {
+		for _, name := range set {
+			if override, ok := flagOverrides[name]; ok {
+				override(cfg, flagConfig)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// overrideSigningKey replaces the signing key with the one given as a flag
+func overrideSigningKey(dst, src *config.Config) {
+	if src.Signing == nil {
+		return
+	}
+	if dst.Signing == nil {
+		dst.Signing = &config.SigningConfig{}
+	}
+	dst.Signing.Key = src.Signing.Key
+}
+
+// overridePackage replaces the package targets with the ones given as flags
+func overridePackage(dst, src *config.Config) {
+	if src.Package == nil {
+		return
+	}
+	if dst.Package == nil {
+		dst.Package = &config.PackageConfig{}
+	}
+	dst.Package.Targets = src.Package.Targets
+}
+
+// overrideProxy replaces the proxy settings with the ones given as flags
+func overrideProxy(dst, src *config.Config) {
+	if src.Proxy != nil {
+		dst.Proxy = src.Proxy
+	}
+}
+
 func main() {
 	// Create subcommands
 	initCmd := flag.NewFlagSet("init", flag.ExitOnError)
@@ -17,14 +130,45 @@ func main() {
 	// Main command flags
 	url := flag.String("url", "", "URL to package")
 	name := flag.String("name", "", "Application name")
-	icon := flag.String("icon", "", "Application icon path")
+	icon := flag.String("icon", "", "Application icon path, or \"auto\" to use the site's favicon")
 	width := flag.Int("width", 1200, "Window width")
 	height := flag.Int("height", 800, "Window height")
 	hideTitleBar := flag.Bool("hide-title-bar", false, "Hide title bar")
 	transparent := flag.Bool("transparent", false, "Enable transparent window")
 	alwaysOnTop := flag.Bool("always-on-top", false, "Keep window always on top")
 	userAgent := flag.String("user-agent", "", "Custom user agent")
+	windowMode := flag.String("window-mode", "", "Window mode: single or windows")
+	proxyURL := flag.String("proxy", "", "Proxy server URL (http, https or socks5)")
+	proxyBypass := flag.String("proxy-bypass", "", "Comma-separated hosts that bypass the proxy")
+	proxyPAC := flag.String("proxy-pac", "", "Proxy auto-config (PAC) URL")
+	dataDir := flag.String("data-dir", "", "Webview data directory (relative to the user config dir)")
+	incognito := flag.Bool("incognito", false, "Use an ephemeral webview profile")
+	theme := flag.String("theme", "", "Theme: light, dark or system")
+	backgroundColor := flag.String("background-color", "", "Window background color, e.g. #1e1e1e")
+	darkFilter := flag.Bool("dark-filter", false, "Invert pages without a native dark theme in dark mode")
+	version := flag.String("version", "", "Application version, or \"git\" to use git describe")
+	description := flag.String("description", "", "Application description")
+	identifier := flag.String("identifier", "", "Reverse-DNS bundle identifier, e.g. com.example.app")
+	company := flag.String("company", "", "Company name")
+	copyright := flag.String("copyright", "", "Copyright notice")
+	author := flag.String("author", "", "Author as \"Name <email>\"")
+	packages := flag.String("package", "", "Comma-separated Linux packages to build: deb, rpm, tar.gz")
+	platforms := flag.String("platform", "", "Comma-separated os/arch targets, e.g. linux/amd64,linux/arm64,windows/amd64,darwin/universal")
+	assetsFlag := flag.String("assets", "", "Comma-separated files, directories or globs bundled into the app under /pake-assets/")
+	templatesDir := flag.String("templates-dir", "", "Directory of templates overriding the generated files")
+	signKey := flag.String("sign-key", "", "Secret key file signing SHA256SUMS of the artifacts (see pake-go keygen)")
 	configFile := flag.String("config", "", "Path to config file")
+	fromManifest := flag.String("from-manifest", "", "Web app manifest URL or file to build from")
+	noCache := flag.Bool("no-cache", false, "Always run a full build instead of reusing a cached one")
+	cacheDir := flag.String("cache-dir", "", "Build cache directory (defaults to the user cache dir)")
+	timeout := flag.Duration("timeout", 0, "Maximum duration of the whole build, e.g. 30m (0 means no limit)")
+	stepTimeout := flag.Duration("step-timeout", 0, "Maximum duration of each build step (0 means no limit)")
+	keepWorkdir := flag.Bool("keep-workdir", false, "Keep the generated project directory after the build")
+	logFormat := flag.String("log-format", "text", "Build log format: text or json")
+	lockFile := flag.String("lock", "", "Lock file pinning the toolchain and dependencies; written by the first build (verify defaults to pake.lock)")
+	updateLock := flag.Bool("update-lock", false, "Rewrite the lock file from a fresh build")
+	offline := flag.Bool("offline", false, "Build without network access from local caches")
+	moduleCache := flag.String("module-cache", "", "Go module cache for offline builds (defaults to GOMODCACHE)")
 
 	// Check if any arguments were provided
 	if len(os.Args) < 2 {
@@ -32,10 +176,18 @@ func main() {
 		fmt.Println("\nCommands:")
 		fmt.Println("  init    Initialize development environment")
 		fmt.Println("  build   Build application (default)")
+		fmt.Println("  verify  Rebuild from pake.lock and compare artifact hashes")
+		fmt.Println("  templates export [dir]  Write the built-in templates into dir (default templates)")
+		fmt.Println("  keygen [name]  Create a signing key pair name.key and name.pub (default pake)")
+		fmt.Println("  verify-artifact -key <name.pub> [-sums SHA256SUMS] [files]  Check checksums and their signature")
+		fmt.Println("  publish-feed -config <file> -key <name.key> -base-url <url>  Write the update feed for the built apps")
+		fmt.Println("  sign -key <name.key> <files>  Write a signature file.sig of every file, e.g. the rules bundle")
+		fmt.Println("  cache clean [-cache-dir dir] [-max-age d]  Remove build cache entries, or only those unused for d")
 		fmt.Println("\nFor build options, run: pake-go build -h")
 		os.Exit(1)
 	}
 
+	verify := false
 	switch os.Args[1] {
 	case "init":
 		initCmd.Parse(os.Args[2:])
@@ -47,52 +199,416 @@ func main() {
 		fmt.Println("Development environment initialized successfully!")
 		return
 
+	case "templates":
+		exportTemplates(os.Args[2:])
+		return
+
+	case "keygen":
+		generateSigningKey(os.Args[2:])
+		return
+
+	case "verify-artifact":
+		verifyArtifacts(os.Args[2:])
+		return
+
+	case "publish-feed":
+		publishFeed(os.Args[2:])
+		return
+
+	case "sign":
+		signFiles(os.Args[2:])
+		return
+
+	case "cache":
+		cleanCache(os.Args[2:])
+		return
+
 	case "build":
 		flag.CommandLine.Parse(os.Args[2:])
+	case "verify":
+		verify = true
+		flag.CommandLine.Parse(os.Args[2:])
+		if *lockFile == "" {
+			*lockFile = "pake.lock"
+		}
 	default:
 		// Treat as build command for backward compatibility
 		flag.CommandLine.Parse(os.Args[1:])
 	}
 
 	// If no URL is provided, show usage
-	if *url == "" && *configFile == "" {
+	if *url == "" && *configFile == "" && *fromManifest == "" {
 		fmt.Println("Usage: pake-go build -url <url> [options]")
 		fmt.Println("   or: pake-go build -config <config-file>")
+		fmt.Println("   or: pake-go build -from-manifest <url-or-file>")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
 	// Create config
 	cfg := &config.Config{
-		URL:          *url,
-		Name:         *name,
-		Icon:         *icon,
-		Width:        *width,
-		Height:       *height,
-		HideTitleBar: *hideTitleBar,
-		Transparent:  *transparent,
-		AlwaysOnTop:  *alwaysOnTop,
-		UserAgent:    *userAgent,
+		URL:             *url,
+		Name:            *name,
+		Icon:            *icon,
+		Width:           *width,
+		Height:          *height,
+		HideTitleBar:    *hideTitleBar,
+		Transparent:     *transparent,
+		AlwaysOnTop:     *alwaysOnTop,
+		UserAgent:       *userAgent,
+		WindowMode:      *windowMode,
+		DataDir:         *dataDir,
+		Incognito:       *incognito,
+		Theme:           *theme,
+		BackgroundColor: *backgroundColor,
+		DarkFilter:      *darkFilter,
+		Version:         *version,
+		Description:     *description,
+		Identifier:      *identifier,
+		Company:         *company,
+		Copyright:       *copyright,
+		Author:          *author,
+		TemplatesDir:    *templatesDir,
 	}
 
-	// If config file is provided, load it
+	if *packages != "" {
+		cfg.Package = &config.PackageConfig{
+			Targets: strings.Split(*packages, ","),
+		}
+	}
+
+	if *platforms != "" {
+		cfg.Platforms = strings.Split(*platforms, ",")
+	}
+
+	if *assetsFlag != "" {
+		cfg.Assets = strings.Split(*assetsFlag, ",")
+	}
+
+	if *signKey != "" {
+		cfg.Signing = &config.SigningConfig{Key: *signKey}
+	}
+
+	if *proxyURL != "" || *proxyPAC != "" {
+		cfg.Proxy = &config.ProxyConfig{
+			URL: *proxyURL,
+			PAC: *proxyPAC,
+		}
+		if *proxyBypass != "" {
+			cfg.Proxy.Bypass = strings.Split(*proxyBypass, ",")
+		}
+	}
+
+	// In JSON mode stdout carries only build events, so errors go to stderr
+	var reporter builder.Reporter
+	switch *logFormat {
+	case "text":
+		reporter = builder.NewTextReporter(os.Stdout)
+	case "json":
+		reporter = builder.NewJSONReporter(os.Stdout)
+		errOut = os.Stderr
+	default:
+		fatalf("Error: unknown log format %q; use text or json\n", *logFormat)
+	}
+
+	var set []string
+	flag.Visit(func(f *flag.Flag) { set = append(set, f.Name) })
+	cfg, err := resolveConfig(cfg, *configFile, *fromManifest, set)
+	if err != nil {
+		fatalf("Error: %v\n", err)
+	}
+
+	if cfg.URL == "" {
+		fatalf("Error: no URL to package; pass -url when the manifest has no absolute start_url\n")
+	}
+
+	// Create builder
+	b := builder.NewBuilder(cfg)
+	b.NoCache = *noCache
+	b.CacheDir = *cacheDir
+	b.Timeout = *timeout
+	b.StepTimeout = *stepTimeout
+	b.KeepWorkdir = *keepWorkdir
+	b.Reporter = reporter
+	b.LockFile = *lockFile
+	b.UpdateLock = *updateLock
+	b.Offline = *offline
+	b.ModuleCache = *moduleCache
+
+	// Stop the build and its child processes on Ctrl+C or when the job runner terminates us
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Verify that the build reproduces the locked artifacts
+	if verify {
+		if err := b.Verify(ctx); err != nil {
+			fatalf("Error verifying build: %v\n", err)
+		}
+		if *logFormat == "text" {
+			fmt.Println("Build verified successfully!")
+		}
+		return
+	}
+
+	// Build the application
+	if err := b.BuildContext(ctx); err != nil {
+		fatalf("Error building application: %v\n", err)
+	}
+
+	if *logFormat == "text" {
+		fmt.Println("Application built successfully!")
+	}
+}
+
+// exportTemplates runs `templates export`, writing the built-in templates of
+// the files generated for a config into a directory
+func exportTemplates(args []string) {
+	if len(args) == 0 || args[0] != "export" {
+		fatalf("Usage: pake-go templates export [-config <config-file>] [dir]\n")
+	}
+
+	exportCmd := flag.NewFlagSet("templates export", flag.ExitOnError)
+	configFile := exportCmd.String("config", "", "Config file selecting the optional templates, such as badge or windows")
+	exportCmd.Parse(args[1:])
+
+	dir := "templates"
+	if exportCmd.NArg() > 0 {
+		dir = exportCmd.Arg(0)
+	}
+
+	cfg := config.DefaultConfig()
 	if *configFile != "" {
 		loadedConfig, err := config.LoadConfig(*configFile)
 		if err != nil {
-			fmt.Printf("Error loading config file: %v\n", err)
-			os.Exit(1)
+			fatalf("Error loading config file: %v\n", err)
 		}
 		cfg = loadedConfig
 	}
 
-	// Create builder
-	b := builder.NewBuilder(cfg)
+	names, err := builder.ExportTemplates(cfg, dir)
+	if err != nil {
+		fatalf("Error exporting templates: %v\n", err)
+	}
+	for _, name := range names {
+		fmt.Println(filepath.Join(dir, filepath.FromSlash(name)))
+	}
+}
 
-	// Build the application
-	if err := b.Build(); err != nil {
-		fmt.Printf("Error building application: %v\n", err)
-		os.Exit(1)
+// cleanCache runs `cache clean`, removing build cache entries
+func cleanCache(args []string) {
+	if len(args) == 0 || args[0] != "clean" {
+		fatalf("Usage: pake-go cache clean [-cache-dir dir] [-max-age duration]\n")
+	}
+
+	cleanCmd := flag.NewFlagSet("cache clean", flag.ExitOnError)
+	cacheDir := cleanCmd.String("cache-dir", "", "Build cache directory (defaults to the user cache dir)")
+	maxAge := cleanCmd.Duration("max-age", 0, "Only remove entries not used for this long, e.g. 720h (default all)")
+	cleanCmd.Parse(args[1:])
+
+	removed, err := builder.CleanCache(*cacheDir, *maxAge)
+	for _, path := range removed {
+		fmt.Println(path)
+	}
+	if err != nil {
+		fatalf("Error cleaning cache: %v\n", err)
+	}
+}
+
+// generateSigningKey runs `keygen`, writing a new signing key pair
+func generateSigningKey(args []string) {
+	keygenCmd := flag.NewFlagSet("keygen", flag.ExitOnError)
+	keygenCmd.Parse(args)
+
+	name := "pake"
+	if keygenCmd.NArg() > 0 {
+		name = keygenCmd.Arg(0)
+	}
+	keyPath, pubPath := name+".key", name+".pub"
+	for _, path := range []string{keyPath, pubPath} {
+		if _, err := os.Lstat(path); err == nil {
+			fatalf("Error: %s already exists\n", path)
+		}
+	}
+
+	key, err := signing.GenerateKey()
+	if err != nil {
+		fatalf("Error generating key: %v\n", err)
+	}
+	if err := os.WriteFile(keyPath, key.Encode(), 0600); err != nil {
+		fatalf("Error writing secret key: %v\n", err)
+	}
+	if err := os.WriteFile(pubPath, key.Public().Encode(), 0644); err != nil {
+		fatalf("Error writing public key: %v\n", err)
+	}
+	fmt.Printf("Wrote secret key %s and public key %s (key ID %s)\n", keyPath, pubPath, key.Public().KeyID())
+	fmt.Println("Keep the secret key private; publish the public key to verify artifacts.")
+}
+
+// verifyArtifacts runs `verify-artifact`, checking the signature of a
+// SHA256SUMS file and the artifacts listed in it
+func verifyArtifacts(args []string) {
+	verifyCmd := flag.NewFlagSet("verify-artifact", flag.ExitOnError)
+	keyFile := verifyCmd.String("key", "", "Public key file the checksums were signed with")
+	sumsFile := verifyCmd.String("sums", filepath.Join("build", signing.ChecksumsFile), "Checksums file")
+	sigFile := verifyCmd.String("sig", "", "Signature file (defaults to the checksums file with .sig)")
+	verifyCmd.Parse(args)
+
+	sums, err := os.ReadFile(*sumsFile)
+	if err != nil {
+		fatalf("Error reading checksums: %v\n", err)
+	}
+
+	if *keyFile == "" {
+		fatalf("Error: no public key; pass -key <name.pub>\n")
+	}
+	key, err := signing.LoadPublicKey(*keyFile)
+	if err != nil {
+		fatalf("Error loading public key: %v\n", err)
+	}
+	if *sigFile == "" {
+		*sigFile = *sumsFile + ".sig"
+	}
+	signature, err := os.ReadFile(*sigFile)
+	if err != nil {
+		fatalf("Error reading signature: %v\n", err)
 	}
+	comment, err := signing.Verify(key, sums, signature)
+	if err != nil {
+		fatalf("Error verifying signature of %s: %v\n", *sumsFile, err)
+	}
+	fmt.Printf("Signature of %s is valid (%s)\n", *sumsFile, comment)
+
+	hashes, err := signing.ParseChecksums(sums)
+	if err != nil {
+		fatalf("Error parsing checksums: %v\n", err)
+	}
+
+	// Artifacts given on the command line are relative to the current directory
+	dir := filepath.Dir(*sumsFile)
+	var names []string
+	for _, arg := range verifyCmd.Args() {
+		name, err := filepath.Rel(dir, arg)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		names = append(names, name)
+	}
+	if err := signing.CheckFiles(dir, hashes, names...); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	checked := len(names)
+	if checked == 0 {
+		checked = len(hashes)
+	}
+	fmt.Printf("%d files match %s\n", checked, *sumsFile)
+}
+
+// publishFeed runs `publish-feed`, signing the built apps and recording them
+// as the latest release of a channel in the update feed
+func publishFeed(args []string) {
+	publishCmd := flag.NewFlagSet("publish-feed", flag.ExitOnError)
+	configFile := publishCmd.String("config", "", "Config file the apps were built from")
+	keyFile := publishCmd.String("key", "", "Secret key file signing the apps (defaults to signing.key or PAKE_SIGNING_KEY)")
+	baseURL := publishCmd.String("base-url", "", "URL the output directory is uploaded to")
+	outputDir := publishCmd.String("output", "build", "Build output directory")
+	feedFile := publishCmd.String("feed", "", "Feed file to update (defaults to feed.json in the output directory)")
+	channel := publishCmd.String("channel", "", "Release channel (defaults to update.channel or stable)")
+	notes := publishCmd.String("notes", "", "Release notes")
+	platform := publishCmd.String("platform", "", "os/arch of an app built without -platform (defaults to this host)")
+	publishCmd.Parse(args)
+
+	if *configFile == "" || *baseURL == "" {
+		fatalf("Usage: pake-go publish-feed -config <config-file> -key <name.key> -base-url <url> [options]\n")
+	}
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		fatalf("Error loading config file: %v\n", err)
+	}
+	if *channel == "" && cfg.Update != nil {
+		*channel = cfg.Update.EffectiveChannel()
+	}
+	if *keyFile == "" && cfg.Signing != nil {
+		*keyFile = cfg.Signing.Key
+	}
+
+	key := loadSigningKey(*keyFile)
+
+	if *feedFile == "" {
+		*feedFile = filepath.Join(*outputDir, "feed.json")
+	}
+	feed, err := update.LoadFeed(*feedFile)
+	if err != nil {
+		fatalf("Error loading feed: %v\n", err)
+	}
+	release, err := update.Publish(feed, cfg, update.Options{
+		OutputDir:    *outputDir,
+		BaseURL:      *baseURL,
+		Channel:      *channel,
+		Notes:        *notes,
+		HostPlatform: *platform,
+		Key:          key,
+	})
+	if err != nil {
+		fatalf("Error publishing release: %v\n", err)
+	}
+	if err := feed.Save(*feedFile); err != nil {
+		fatalf("Error writing feed: %v\n", err)
+	}
+
+	for _, platform := range release.SortedPlatforms() {
+		fmt.Printf("%s: %s\n", platform, release.Platforms[platform].URL)
+	}
+	fmt.Printf("Published %s to %s\n", release.Version, *feedFile)
+}
+
+// signFiles runs `sign`, writing a detached signature next to every file,
+// e.g. for the rules bundle generated apps fetch
+func signFiles(args []string) {
+	signCmd := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyFile := signCmd.String("key", "", "Secret key file (defaults to PAKE_SIGNING_KEY)")
+	signCmd.Parse(args)
+
+	if signCmd.NArg() == 0 {
+		fatalf("Usage: pake-go sign -key <name.key> <files>\n")
+	}
+	key := loadSigningKey(*keyFile)
+	for _, file := range signCmd.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fatalf("Error reading %s: %v\n", file, err)
+		}
+		signature := signing.Sign(key, data, "file:"+filepath.Base(file))
+		if err := os.WriteFile(file+".sig", signature, 0644); err != nil {
+			fatalf("Error writing signature: %v\n", err)
+		}
+		fmt.Printf("Signed %s\n", file)
+	}
+}
+
+// loadSigningKey loads the secret key file, or the key in PAKE_SIGNING_KEY
+// without one
+func loadSigningKey(keyFile string) *signing.PrivateKey {
+	var key *signing.PrivateKey
+	var err error
+	switch {
+	case keyFile != "":
+		key, err = signing.LoadPrivateKey(keyFile)
+	case os.Getenv("PAKE_SIGNING_KEY") != "":
+		key, err = signing.ParsePrivateKey([]byte(os.Getenv("PAKE_SIGNING_KEY")))
+	default:
+		err = fmt.Errorf("no key; pass -key <name.key>")
+	}
+	if err != nil {
+		fatalf("Error loading signing key: %v\n", err)
+	}
+	return key
+}
+
+// errOut receives build errors
+var errOut io.Writer = os.Stdout
 
-	fmt.Println("Application built successfully!")
+// fatalf prints an error and exits
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(errOut, format, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestFlagsOverrideManifest(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	manifest := `{"name": "ManifestName", "start_url": "https://manifest.example.com/", "display": "standalone"}`
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flagConfig := &config.Config{Name: "FlagName", Width: 1200}
+	cfg, err := resolveConfig(flagConfig, "", manifestPath, []string{"name"})
+	if err != nil {
+		t.Fatalf("Failed to resolve config: %v", err)
+	}
+	if cfg.Name != "FlagName" {
+		t.Errorf("Expected the -name flag to win over the manifest, got %q", cfg.Name)
+	}
+	if cfg.URL != "https://manifest.example.com/" || !cfg.HideTitleBar {
+		t.Errorf("Expected the manifest settings without flags, got %q %v", cfg.URL, cfg.HideTitleBar)
+	}
+	if flagConfig.Name != "FlagName" || flagConfig.URL != "" {
+		t.Errorf("Expected the flag config to be left alone, got %+v", flagConfig)
+	}
+}
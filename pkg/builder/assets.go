@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// assetsPath is where the bundled assets are served by the embedded asset
+// server, relative to its root
+const assetsPath = "pake-assets"
+
+// generateAssets copies the files and directories matched by the assets
+// globs into frontend/public, which vite copies into frontend/dist
+func (b *Builder) generateAssets(projectDir string) error {
+	dstDir := filepath.Join(projectDir, "frontend", "public", assetsPath)
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return err
+	}
+
+	sources := map[string]string{}
+	for _, pattern := range b.config.Assets {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid asset pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("asset %q matches no files", pattern)
+		}
+
+		for _, src := range matches {
+			name := filepath.Base(src)
+			if other, ok := sources[name]; ok && other != src {
+				return fmt.Errorf("assets %s and %s have the same name", other, src)
+			}
+			sources[name] = src
+
+			info, err := os.Stat(src)
+			if err != nil {
+				return err
+			}
+			dst := filepath.Join(dstDir, name)
+			if info.IsDir() {
+				err = copyTree(src, dst)
+			} else {
+				err = copyFile(src, dst, info.Mode().Perm())
+			}
+			if err != nil {
+				return fmt.Errorf("failed to copy asset %s: %w", src, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyPublicAssets copies frontend/public into frontend/dist, as vite would,
+// for builds that skip the frontend build
+func copyPublicAssets(frontendDir string) error {
+	publicDir := filepath.Join(frontendDir, "public")
+	if _, err := os.Stat(publicDir); os.IsNotExist(err) {
+		return nil
+	}
+	return copyTree(publicDir, filepath.Join(frontendDir, "dist"))
+}
+
+const assetsTemplate = `package main
+
+import (
+	"encoding/json"
+	goruntime "runtime"
+)
+
+// assetsBaseURL returns the URL the embedded asset server serves the bundled
+// assets from; pages of any origin can load them
+func assetsBaseURL() string {
+	if goruntime.GOOS == "windows" {
+		return "http://wails.localhost/pake-assets/"
+	}
+	return "wails://wails/pake-assets/"
+}
+
+// assetsScript defines window.pakeAsset, which returns the URL of a bundled
+// asset, e.g. pakeAsset('toolbar.js')
+func assetsScript() string {
+	base, _ := json.Marshal(assetsBaseURL())
+	return "window.pakeAssetsURL = " + string(base) + ";\n" +
+		"window.pakeAsset = function(name) { return window.pakeAssetsURL + String(name).replace(/^\\/+/, ''); };"
+}
+`
@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestGenerateAssets(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"toolbar.js":          "toolbar()",
+		"help/index.html":     "<h1>Help</h1>",
+		"fonts/brand.woff2":   "font",
+		"fonts/brand.license": "OFL",
+	})
+
+	cfg := config.DefaultConfig()
+	cfg.Assets = []string{
+		filepath.Join(src, "toolbar.js"),
+		filepath.Join(src, "help"),
+		filepath.Join(src, "fonts", "*.woff2"),
+	}
+	projectDir := t.TempDir()
+	b := NewBuilder(cfg)
+	if err := b.generateAssets(projectDir); err != nil {
+		t.Fatalf("Failed to bundle assets: %v", err)
+	}
+
+	publicDir := filepath.Join(projectDir, "frontend", "public", assetsPath)
+	for _, name := range []string{"toolbar.js", "help/index.html", "brand.woff2"} {
+		if _, err := os.Stat(filepath.Join(publicDir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("Expected asset %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(publicDir, "brand.license")); !os.IsNotExist(err) {
+		t.Errorf("Expected files outside the glob to be skipped")
+	}
+
+	// Builds without the frontend build get the assets from public
+	frontendDir := filepath.Join(projectDir, "frontend")
+	if err := copyPublicAssets(frontendDir); err != nil {
+		t.Fatalf("Failed to copy assets: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(frontendDir, "dist", assetsPath, "toolbar.js")); err != nil {
+		t.Errorf("Expected assets in dist: %v", err)
+	}
+
+	cfg.Assets = []string{filepath.Join(src, "missing-*.js")}
+	if err := b.generateAssets(t.TempDir()); err == nil || !strings.Contains(err.Error(), "matches no files") {
+		t.Errorf("Expected an error for a glob without matches, got %v", err)
+	}
+
+	other := t.TempDir()
+	writeFiles(t, other, map[string]string{"toolbar.js": "other()"})
+	cfg.Assets = []string{filepath.Join(src, "toolbar.js"), filepath.Join(other, "toolbar.js")}
+	if err := b.generateAssets(t.TempDir()); err == nil || !strings.Contains(err.Error(), "same name") {
+		t.Errorf("Expected an error for assets with the same name, got %v", err)
+	}
+}
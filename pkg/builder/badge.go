@@ -0,0 +1,121 @@
+package builder
+
+const badgeTemplate = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	appTitle         = {{printf "%q" .Name}}
+	badgePattern     = {{printf "%q" .Badge.EffectivePattern}}
+	badgeExpression  = {{printf "%q" .Badge.Expression}}
+	badgeMirrorTitle = {{.Badge.Title}}
+)
+
+// SetBadge is called by the page whenever the unread counter changes
+func (a *App) SetBadge(count string) {
+	setDockBadge(count)
+
+	if badgeMirrorTitle && a.ctx != nil {
+		title := appTitle
+		if count != "" {
+			title = fmt.Sprintf("(%s) %s", count, appTitle)
+		}
+		runtime.WindowSetTitle(a.ctx, title)
+	}
+}
+
+// badgeScript returns the JavaScript that watches document.title
+func badgeScript() string {
+	pattern, _ := json.Marshal(badgePattern)
+	expression, _ := json.Marshal(badgeExpression)
+
+	return fmt.Sprintf(` + "`" + `
+		(function() {
+			if (window.__pakeBadge) return;
+			window.__pakeBadge = true;
+
+			var pattern = null;
+			var expression = %s;
+			var last = null;
+			try {
+				pattern = new RegExp(%s);
+			} catch (e) {
+				console.warn('Invalid badge pattern:', e);
+			}
+
+			function read() {
+				var value = '';
+				try {
+					if (expression) {
+						value = (0, eval)(expression);
+					} else if (pattern) {
+						var match = (document.title || '').match(pattern);
+						if (match) value = match[1] !== undefined ? match[1] : match[0];
+					}
+				} catch (e) {
+					console.error('Failed to read badge:', e);
+				}
+
+				value = (value === undefined || value === null || value === false) ? '' : String(value);
+				if (value === '0') value = '';
+				if (value === last) return;
+				last = value;
+
+				if (window.go && window.go.main && window.go.main.App) {
+					window.go.main.App.SetBadge(value);
+				}
+			}
+
+			new MutationObserver(read).observe(document.head || document.documentElement, {
+				subtree: true,
+				childList: true,
+				characterData: true
+			});
+			read();
+		})();
+	` + "`" + `, expression, pattern)
+}
+`
+
+const badgeDarwinTemplate = `//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework Cocoa
+#include <stdlib.h>
+#import <Cocoa/Cocoa.h>
+
+static void pakeSetDockBadge(const char *label) {
+	NSString *text = [NSString stringWithUTF8String:label];
+	dispatch_async(dispatch_get_main_queue(), ^{
+		[[NSApp dockTile] setBadgeLabel:([text length] > 0 ? text : nil)];
+	});
+}
+*/
+import "C"
+
+import "unsafe"
+
+// setDockBadge shows the counter on the macOS dock icon
+func setDockBadge(label string) {
+	cLabel := C.CString(label)
+	defer C.free(unsafe.Pointer(cLabel))
+	C.pakeSetDockBadge(cLabel)
+}
+`
+
+const badgeOtherTemplate = `//go:build !darwin
+
+package main
+
+// setDockBadge is a no-op on platforms without a dock badge; Wails v2 has no
+// tray or taskbar overlay API, so the window title mirror is used instead
+func setDockBadge(label string) {}
+`
@@ -1,266 +1,533 @@
 package builder
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"text/template"
+	"strings"
+	"time"
 
 	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/favicon"
+	"github.com/zk3151463/pake-go/pkg/httpclient"
+	"github.com/zk3151463/pake-go/pkg/icon"
+	"github.com/zk3151463/pake-go/pkg/packager"
 )
 
 // Builder handles the application building process
 type Builder struct {
 	config *config.Config
+
+	// CacheDir holds cached builds and node_modules. Empty uses the user cache dir.
+	CacheDir string
+	// NoCache always runs a full build
+	NoCache bool
+	// Timeout limits the whole build, StepTimeout each build step. Zero means no limit.
+	Timeout     time.Duration
+	StepTimeout time.Duration
+	// KeepWorkdir keeps the generated project directory after the build
+	KeepWorkdir bool
+	// Reporter receives build progress events
+	Reporter Reporter
+	// OutputDir receives the built apps and packages. Empty uses build.
+	OutputDir string
+	// LockFile pins the toolchain, dependencies and build timestamp. It is
+	// written by the first build and used by later ones.
+	LockFile string
+	// UpdateLock rewrites LockFile from a fresh build
+	UpdateLock bool
+	// Offline builds without network access from the local module cache and
+	// cached node_modules, or a static frontend shell
+	Offline bool
+	// ModuleCache is the Go module cache used by offline builds. Empty uses GOMODCACHE.
+	ModuleCache string
+
+	currentStep     string
+	currentTarget   string
+	toolchain       Toolchain
+	env             []string
+	creatingLock    bool
+	skipFrontend    bool
+	artifacts       []string
+	artifactTargets map[string]string
+	artifactHashes  map[string]string
+	verifying       bool
 }
 
 // NewBuilder creates a new Builder instance
 func NewBuilder(config *config.Config) *Builder {
 	return &Builder{
-		config: config,
+		config:   config,
+		Reporter: NewTextReporter(os.Stdout),
 	}
 }
 
 // Build builds the application
 func (b *Builder) Build() error {
-	// Create project directory
-	projectDir := filepath.Join("build", b.config.Name)
-	if err := os.MkdirAll(projectDir, 0755); err != nil {
-		return fmt.Errorf("failed to create project directory: %w", err)
-	}
+	return b.BuildContext(context.Background())
+}
 
-	// Generate main.go
-	if err := b.generateMainGo(projectDir); err != nil {
-		return fmt.Errorf("failed to generate main.go: %w", err)
+// BuildContext builds the application, stopping child processes when ctx is
+// cancelled or a timeout expires. The project is generated in a unique temp
+// directory that is removed whether or not the build succeeds, unless
+// KeepWorkdir is set.
+func (b *Builder) BuildContext(ctx context.Context) (err error) {
+	if err := b.config.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	// Generate go.mod
-	if err := b.generateGoMod(projectDir); err != nil {
-		return fmt.Errorf("failed to generate go.mod: %w", err)
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, b.Timeout, fmt.Errorf("build timed out after %s", b.Timeout))
+		defer cancel()
 	}
 
-	// Copy icon if provided
-	if b.config.Icon != "" {
-		if err := b.copyIcon(projectDir); err != nil {
-			return fmt.Errorf("failed to copy icon: %w", err)
+	// Pin the toolchain, dependencies and timestamps from the lock file
+	b.artifacts = nil
+	b.artifactTargets = nil
+	lock, err := b.prepareLock()
+	if err != nil {
+		return err
+	}
+	b.skipFrontend = false
+	if b.Offline {
+		b.env = append(b.env, offlineEnv(ctx)...)
+		if b.ModuleCache != "" {
+			b.env = append(b.env, "GOMODCACHE="+b.ModuleCache)
 		}
 	}
 
-	// Generate wails.json
-	if err := b.generateWailsConfig(projectDir); err != nil {
-		return fmt.Errorf("failed to generate wails.json: %w", err)
+	if err := b.resolveVersion(ctx); err != nil {
+		return err
 	}
 
-	// Generate frontend
-	if err := b.generateFrontend(projectDir); err != nil {
-		return fmt.Errorf("failed to generate frontend: %w", err)
+	// Create a project directory of our own so concurrent or failed builds
+	// never see each other's files
+	projectDir, err := os.MkdirTemp("", "pake-go-"+packager.PackageName(b.config.Name)+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
 	}
+	defer func() {
+		if b.KeepWorkdir {
+			b.logf("Keeping project directory %s", projectDir)
+			return
+		}
+		if cleanupErr := os.RemoveAll(projectDir); cleanupErr != nil && err == nil {
+			err = fmt.Errorf("failed to clean up project directory: %w", cleanupErr)
+		}
+	}()
 
-	// Build the application
-	if err := b.runWailsBuild(projectDir); err != nil {
-		return fmt.Errorf("failed to build application: %w", err)
+	if err := b.runHooks(ctx, HookPreGenerate, projectDir); err != nil {
+		return err
 	}
 
-	// Move the built application to the final location
-	builtAppPath := filepath.Join(projectDir, "build", "bin")
-	finalAppPath := filepath.Join("build", "bin")
-	if err := os.MkdirAll(filepath.Dir(finalAppPath), 0755); err != nil {
-		return fmt.Errorf("failed to create final directory: %w", err)
+	// Generate the project
+	if err := b.step(ctx, "generate", func(ctx context.Context) error {
+		return b.generateProject(projectDir)
+	}); err != nil {
+		return err
 	}
 
-	// Remove existing app if it exists
-	if err := os.RemoveAll(finalAppPath); err != nil {
-		return fmt.Errorf("failed to remove existing app: %w", err)
+	// Hooks may patch the project before it is hashed for the cache and lock
+	if err := b.runHooks(ctx, HookPostGenerate, projectDir); err != nil {
+		return err
 	}
 
-	// Move the built app to final location
-	if err := os.Rename(builtAppPath, finalAppPath); err != nil {
-		return fmt.Errorf("failed to move built app: %w", err)
+	// Check the project against the lock file and restore locked dependencies
+	if lock != nil {
+		if err := b.applyLock(lock, projectDir); err != nil {
+			return err
+		}
 	}
 
-	// Clean up the temporary project directory
-	if err := os.RemoveAll(projectDir); err != nil {
-		return fmt.Errorf("failed to clean up project directory: %w", err)
+	// Fail fast when an offline build lacks anything it would download
+	if b.Offline {
+		if err := b.step(ctx, "offline check", func(ctx context.Context) error {
+			return b.prepareOffline(ctx, projectDir)
+		}); err != nil {
+			return err
+		}
 	}
 
-	return nil
-}
+	// Build for the host, or for every configured platform
+	var buildErr error
+	if len(b.config.Platforms) == 0 {
+		buildErr = b.buildTarget(ctx, projectDir, target{}, filepath.Join(b.outputDir(), "bin"))
+	} else {
+		buildErr = b.buildMatrix(ctx, projectDir)
+	}
+	if buildErr != nil {
+		return buildErr
+	}
 
-// generateMainGo generates the main.go file
-func (b *Builder) generateMainGo(projectDir string) error {
-	// Create webview manager file
-	webviewFile, err := os.Create(filepath.Join(projectDir, "webview.go"))
-	if err != nil {
+	// The lock records the artifacts as built, before they are signed
+	if lock != nil {
+		if err := b.recordLock(lock, projectDir); err != nil {
+			return err
+		}
+	}
+
+	if b.verifying {
+		return nil
+	}
+	// Hooks may change the artifacts, e.g. notarize them, before they are
+	// checksummed and signed
+	if err := b.runHooks(ctx, HookPreSign, projectDir); err != nil {
 		return err
 	}
-	defer webviewFile.Close()
+	if b.config.Signing != nil {
+		if err := b.step(ctx, "sign", b.signArtifacts); err != nil {
+			return err
+		}
+	}
+	return b.runHooks(ctx, HookPostBuild, projectDir)
+}
 
-	webviewTmpl := template.Must(template.New("webview").Parse(webviewManagerTemplate))
-	if err := webviewTmpl.Execute(webviewFile, nil); err != nil {
+// outputDir returns the directory receiving the built apps and packages
+func (b *Builder) outputDir() string {
+	if b.OutputDir != "" {
+		return b.OutputDir
+	}
+	return "build"
+}
+
+// buildTarget builds, installs and packages the application for one target
+func (b *Builder) buildTarget(ctx context.Context, projectDir string, t target, finalAppPath string) error {
+	b.warnUnsupportedProfile(t)
+
+	// Build the application, reusing a cached build of an identical project
+	builtAppPath := filepath.Join(projectDir, "build", "bin")
+	if err := b.step(ctx, "wails build", func(ctx context.Context) error {
+		if err := b.buildCached(ctx, projectDir, t, builtAppPath); err != nil {
+			return fmt.Errorf("failed to build application: %w", err)
+		}
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	// Create main.go file
-	mainFile, err := os.Create(filepath.Join(projectDir, "main.go"))
-	if err != nil {
+	// Move the built application to the final location
+	if err := b.step(ctx, "install", func(ctx context.Context) error {
+		if err := installApp(builtAppPath, finalAppPath); err != nil {
+			return err
+		}
+		b.reportArtifacts(finalAppPath)
+		return nil
+	}); err != nil {
 		return err
 	}
-	defer mainFile.Close()
 
-	mainTmpl := template.Must(template.New("main").Parse(mainTemplate))
-	return mainTmpl.Execute(mainFile, b.config)
+	// Build Linux packages
+	return b.step(ctx, "package", func(ctx context.Context) error {
+		return b.packageLinux(projectDir, finalAppPath, t)
+	})
 }
 
-// generateGoMod generates the go.mod file
-func (b *Builder) generateGoMod(projectDir string) error {
-	tmpl := template.Must(template.New("go.mod").Parse(goModTemplate))
-	file, err := os.Create(filepath.Join(projectDir, "go.mod"))
-	if err != nil {
-		return err
+// warnUnsupportedProfile warns that the data directory settings have no
+// effect on macOS, where WKWebView keeps data per bundle identifier
+func (b *Builder) warnUnsupportedProfile(t target) {
+	if goos, _ := t.osArch(); goos != "darwin" {
+		return
+	}
+	if b.config.Incognito {
+		b.warnf("incognito is not supported on macOS, the app keeps its data")
+	}
+	if b.config.DataDir != "" {
+		b.warnf("dataDir is not supported on macOS, the app keeps its data per bundle identifier")
 	}
-	defer file.Close()
-
-	return tmpl.Execute(file, b.config)
 }
 
-// copyIcon copies the icon file to the project directory
-func (b *Builder) copyIcon(projectDir string) error {
-	src, err := os.Open(b.config.Icon)
-	if err != nil {
-		return err
+// step runs one build step with the per-step timeout. When the step fails
+// because the build was cancelled, the cancellation cause is reported.
+func (b *Builder) step(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s: %w", name, context.Cause(ctx))
 	}
-	defer src.Close()
 
-	dst, err := os.Create(filepath.Join(projectDir, "icon.png"))
-	if err != nil {
-		return err
+	if b.StepTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, b.StepTimeout, fmt.Errorf("timed out after %s", b.StepTimeout))
+		defer cancel()
 	}
-	defer dst.Close()
 
-	_, err = dst.ReadFrom(src)
-	return err
-}
+	b.currentStep = name
+	defer func() { b.currentStep = "" }()
+
+	start := time.Now()
+	b.report(Event{Type: EventStepStarted})
+
+	err := fn(ctx)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%s: %w", name, context.Cause(ctx))
+	}
 
-// generateWailsConfig generates the wails.json file
-func (b *Builder) generateWailsConfig(projectDir string) error {
-	tmpl := template.Must(template.New("wails.json").Parse(wailsConfigTemplate))
-	file, err := os.Create(filepath.Join(projectDir, "wails.json"))
 	if err != nil {
+		b.report(Event{Type: EventStepFailed, Duration: time.Since(start), Error: err.Error()})
 		return err
 	}
-	defer file.Close()
+	b.report(Event{Type: EventStepFinished, Duration: time.Since(start)})
+	return nil
+}
 
-	return tmpl.Execute(file, b.config)
+// reportArtifacts reports every file or bundle in dir as an artifact
+func (b *Builder) reportArtifacts(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		b.report(Event{Type: EventArtifact, Artifact: filepath.Join(dir, entry.Name())})
+	}
 }
 
-// generateFrontend generates the frontend files
-func (b *Builder) generateFrontend(projectDir string) error {
-	frontendDir := filepath.Join(projectDir, "frontend")
-	if err := os.MkdirAll(frontendDir, 0755); err != nil {
-		return err
+// generateProject renders every file of the Wails project into projectDir
+func (b *Builder) generateProject(projectDir string) error {
+	if err := b.checkTemplatesDir(); err != nil {
+		return fmt.Errorf("failed to read templates: %w", err)
 	}
 
-	// Generate package.json
-	if err := b.generatePackageJSON(frontendDir); err != nil {
-		return err
+	// Generate main.go
+	if err := b.generateMainGo(projectDir); err != nil {
+		return fmt.Errorf("failed to generate main.go: %w", err)
 	}
 
-	// Generate vite.config.js
-	if err := b.generateViteConfig(frontendDir); err != nil {
-		return err
+	// Generate go.mod
+	if err := b.generateGoMod(projectDir); err != nil {
+		return fmt.Errorf("failed to generate go.mod: %w", err)
 	}
 
-	// Generate src directory
-	srcDir := filepath.Join(frontendDir, "src")
-	if err := os.MkdirAll(srcDir, 0755); err != nil {
-		return err
+	// Generate icons if provided
+	if b.config.Icon != "" {
+		if err := b.generateIcons(projectDir); err != nil {
+			return fmt.Errorf("failed to generate icons: %w", err)
+		}
 	}
 
-	// Generate App.vue
-	if err := b.generateAppVue(srcDir); err != nil {
-		return err
+	// Generate wails.json
+	if err := b.generateWailsConfig(projectDir); err != nil {
+		return fmt.Errorf("failed to generate wails.json: %w", err)
 	}
 
-	// Generate main.js
-	if err := b.generateMainJS(srcDir); err != nil {
-		return err
+	// Generate frontend
+	if err := b.generateFrontend(projectDir); err != nil {
+		return fmt.Errorf("failed to generate frontend: %w", err)
 	}
 
-	// Generate index.html
-	if err := b.generateIndexHTML(frontendDir); err != nil {
-		return err
+	// Bundle the configured assets
+	if len(b.config.Assets) > 0 {
+		if err := b.generateAssets(projectDir); err != nil {
+			return fmt.Errorf("failed to bundle assets: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// generatePackageJSON generates the package.json file
-func (b *Builder) generatePackageJSON(frontendDir string) error {
-	tmpl := template.Must(template.New("package.json").Parse(packageJSONTemplate))
-	file, err := os.Create(filepath.Join(frontendDir, "package.json"))
+// buildCached runs wails build unless the cache holds a build of the same
+// project and toolchain, and keeps node_modules between builds
+func (b *Builder) buildCached(ctx context.Context, projectDir string, t target, builtAppPath string) error {
+	if b.NoCache {
+		return b.runWailsBuild(ctx, projectDir, t)
+	}
+
+	cache, err := newBuildCache(b.CacheDir, b.toolchain)
 	if err != nil {
-		return err
+		b.warnf("build cache disabled: %v", err)
+		return b.runWailsBuild(ctx, projectDir, t)
 	}
-	defer file.Close()
 
-	return tmpl.Execute(file, b.config)
-}
+	// The static shell makes a different app from the same project
+	variant := t.platform
+	if b.skipFrontend {
+		variant += " shell"
+	}
+	if b.config.WebKit == config.WebKit41 {
+		variant += " webkit2_41"
+	}
+	key, err := cache.key(projectDir, variant)
+	if err != nil {
+		return fmt.Errorf("failed to hash project: %w", err)
+	}
 
-// generateViteConfig generates the vite.config.js file
-func (b *Builder) generateViteConfig(frontendDir string) error {
-	tmpl := template.Must(template.New("vite.config.js").Parse(viteConfigTemplate))
-	file, err := os.Create(filepath.Join(frontendDir, "vite.config.js"))
+	// A new lock file records the dependencies resolved by a real build
+	if !b.creatingLock {
+		if hit, err := cache.lookup(key, builtAppPath); err != nil {
+			b.warnf("failed to read build cache: %v", err)
+		} else if hit {
+			b.logf("Using cached build %s", key[:12])
+			return nil
+		}
+	}
+
+	frontendDir := filepath.Join(projectDir, "frontend")
+	cached, err := cache.frontendCache(frontendDir)
 	if err != nil {
+		return fmt.Errorf("failed to hash frontend: %w", err)
+	}
+	if err := cache.restoreFrontend(cached, frontendDir); err != nil {
+		b.warnf("failed to restore node_modules: %v", err)
+	}
+
+	if err := b.runWailsBuild(ctx, projectDir, t); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	return tmpl.Execute(file, b.config)
+	// Only a successful build caches node_modules, so a failed npm install
+	// is not reused
+	if err := cache.saveFrontend(frontendDir, cached); err != nil {
+		b.warnf("failed to cache node_modules: %v", err)
+	}
+	if err := cache.store(key, builtAppPath); err != nil {
+		b.warnf("failed to cache build: %v", err)
+	}
+	if _, err := cache.evict(cacheMaxAge); err != nil {
+		b.warnf("failed to evict old cache entries: %v", err)
+	}
+	return nil
 }
 
-// generateAppVue generates the App.vue file
-func (b *Builder) generateAppVue(srcDir string) error {
-	tmpl := template.Must(template.New("App.vue").Parse(appVueTemplate))
-	file, err := os.Create(filepath.Join(srcDir, "App.vue"))
+// resolveVersion derives the version from git if requested. Without a tag
+// the default version is used, as a bare commit hash is no version.
+func (b *Builder) resolveVersion(ctx context.Context) error {
+	if b.config.Version != config.VersionFromGit {
+		return nil
+	}
+
+	version, err := b.gitDescribe(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if err != nil {
-		return err
+		b.warnf("failed to derive version from git, using %s: %v", config.DefaultVersion, err)
+		version = config.DefaultVersion
 	}
-	defer file.Close()
+	b.config.Version = version
+	return nil
+}
 
-	return tmpl.Execute(file, b.config)
+// gitDescribe returns the version of the current git checkout from its
+// latest tag
+func (b *Builder) gitDescribe(ctx context.Context) (string, error) {
+	cmd := command(ctx, "git", "describe", "--tags", "--dirty")
+	cmd.Env = b.childEnv()
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+			return "", errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), "v"), nil
+}
+
+// generateMainGo generates main.go and the Go files supporting it
+func (b *Builder) generateMainGo(projectDir string) error {
+	for _, name := range []string{
+		"webview.go",
+		"match.go",
+		"main.go",
+		"badge.go",
+		"badge_darwin.go",
+		"badge_other.go",
+		"theme.go",
+		"profile.go",
+		"proxy.go",
+		"windows.go",
+		"assets.go",
+		"update.go",
+		"injection.go",
+		"rules.go",
+		"download.go",
+	} {
+		if err := b.writeProjectFile(projectDir, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateGoMod generates the go.mod file
+func (b *Builder) generateGoMod(projectDir string) error {
+	return b.writeProjectFile(projectDir, "go.mod")
 }
 
-// generateMainJS generates the main.js file
-func (b *Builder) generateMainJS(srcDir string) error {
-	tmpl := template.Must(template.New("main.js").Parse(mainJSTemplate))
-	file, err := os.Create(filepath.Join(srcDir, "main.js"))
+// generateIcons converts the icon into every platform format under build/
+func (b *Builder) generateIcons(projectDir string) error {
+	path := b.config.Icon
+	if path == config.IconAuto {
+		finder := favicon.NewFinder(httpclient.New(b.config.Proxy), "")
+		finder.Offline = b.Offline
+		found, err := finder.Fetch(b.config.URL)
+		if err != nil {
+			b.warnf("no icon found for %s, using the default icon: %v", b.config.URL, err)
+			return nil
+		}
+		path = found
+	}
+
+	img, err := icon.Load(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	return tmpl.Execute(file, b.config)
+	if bounds := img.Bounds(); bounds.Dx() < icon.AppIconSize || bounds.Dy() < icon.AppIconSize {
+		b.warnf("icon is %dx%d and will be upscaled to %dx%d",
+			bounds.Dx(), bounds.Dy(), icon.AppIconSize, icon.AppIconSize)
+	}
+
+	return icon.Generate(img, filepath.Join(projectDir, "build"), b.config.Name)
 }
 
-// generateIndexHTML generates the index.html file
-func (b *Builder) generateIndexHTML(frontendDir string) error {
-	tmpl := template.Must(template.New("index.html").Parse(indexHTMLTemplate))
-	file, err := os.Create(filepath.Join(frontendDir, "index.html"))
-	if err != nil {
+// generateWailsConfig generates the wails.json file and the macOS Info.plist
+func (b *Builder) generateWailsConfig(projectDir string) error {
+	if err := b.writeProjectFile(projectDir, "wails.json"); err != nil {
 		return err
 	}
-	defer file.Close()
+	return b.writeProjectFile(projectDir, "build/darwin/Info.plist")
+}
+
+// generateFrontend generates the frontend files
+func (b *Builder) generateFrontend(projectDir string) error {
+	for _, name := range []string{
+		"frontend/package.json",
+		"frontend/vite.config.js",
+		"frontend/src/App.vue",
+		"frontend/src/main.js",
+		"frontend/index.html",
+	} {
+		if err := b.writeProjectFile(projectDir, name); err != nil {
+			return err
+		}
+	}
 
-	return tmpl.Execute(file, b.config)
+	return nil
 }
 
 // runWailsBuild runs the wails build command
-func (b *Builder) runWailsBuild(projectDir string) error {
-	cmd := exec.Command("wails", "build")
+func (b *Builder) runWailsBuild(ctx context.Context, projectDir string, t target) error {
+	args := []string{"build", "-trimpath"}
+	if t.platform != "" {
+		args = append(args, "-platform", t.platform)
+	}
+	if b.skipFrontend {
+		args = append(args, "-s")
+	}
+	if b.config.WebKit == config.WebKit41 {
+		args = append(args, "-tags", "webkit2_41")
+	}
+	cmd := command(ctx, "wails", args...)
 	cmd.Dir = projectDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Env = b.childEnv(t.env...)
+	output := &logWriter{b: b}
+	defer output.Close()
+	cmd.Stdout = output
+	cmd.Stderr = output
 	return cmd.Run()
 }
 
@@ -269,19 +536,37 @@ const mainTemplate = `package main
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+	"github.com/wailsapp/wails/v2/pkg/options/linux"
 	"github.com/wailsapp/wails/v2/pkg/options/mac"
+	"github.com/wailsapp/wails/v2/pkg/options/windows"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 //go:embed all:frontend/dist
 var assets embed.FS
 
+// defaultURL is the page the app was built for
+const defaultURL = {{printf "%q" .URL}}
+
+// startURL returns the page to open, which can be overridden with -pake-url
+func startURL() string {
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "-pake-url=") {
+			return strings.TrimPrefix(arg, "-pake-url=")
+		}
+	}
+	return defaultURL
+}
+
 // App struct
 type App struct {
 	ctx context.Context
@@ -297,15 +582,27 @@ func NewApp() *App {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 }
+{{- if .Injects}}
+
+// InjectionScript returns the script applying the injection rules to url
+func (a *App) InjectionScript(url string) string {
+	return rulesInjectionScript(url)
+}
+{{- end}}
 
 // domReady is called after the front-end dom has been loaded
 func (a *App) domReady(ctx context.Context) {
+{{- if .Assets}}
+	// 提供打包资源的地址，注入的脚本可以通过 pakeAsset(name) 引用
+	runtime.WindowExecJS(ctx, assetsScript())
+
+{{end}}
+	target, _ := json.Marshal(startURL())
+
 	// 使用 JavaScript 重定向到目标 URL，并处理背景色
 	script := fmt.Sprintf(` + "`" + `
 		(function() {
-			// 设置背景色
-			document.body.style.backgroundColor = '#ffffff';
-			document.documentElement.style.backgroundColor = '#ffffff';
+			var startURL = %s;
 
 			// 设置用户代理和浏览器特性
 			Object.defineProperties(navigator, {
@@ -391,10 +688,23 @@ func (a *App) domReady(ctx context.Context) {
 				}
 			});
 
-			// 使用 sessionStorage 来防止循环重定向
-			if (!sessionStorage.getItem('hasRedirected') && window.location.href !== "{{.URL}}") {
+
+			// 只从应用自带的页面跳转到目标 URL，使用 sessionStorage 来防止循环重定向
+			var isShell = window.location.protocol === 'wails:' || window.location.hostname === 'wails.localhost';
+			if (isShell && !sessionStorage.getItem('hasRedirected') && window.location.href !== startURL) {
 				sessionStorage.setItem('hasRedirected', 'true');
-				window.location.href = "{{.URL}}";
+				window.location.href = startURL;
+			}
+
+			// 按窗口模式打开新文档
+			function openDocument(href) {
+{{- if eq .WindowMode "windows"}}
+				if (window.go && window.go.main && window.go.main.App && window.go.main.App.OpenWindow) {
+					window.go.main.App.OpenWindow(new URL(href, window.location.href).href);
+					return;
+				}
+{{- end}}
+				window.location.href = href;
 			}
 
 			// 处理所有链接点击事件
@@ -414,7 +724,7 @@ func (a *App) domReady(ctx context.Context) {
 						
 						// 处理新标签页打开
 						if (target.getAttribute('target') === '_blank') {
-							window.location.href = href;
+							openDocument(href);
 							return;
 						}
 
@@ -454,23 +764,53 @@ func (a *App) domReady(ctx context.Context) {
 			const originalOpen = window.open;
 			window.open = function(url, target, features) {
 				if (url && (url.startsWith('http') || url.startsWith('//'))) {
-					window.location.href = url;
+					openDocument(url);
 					return null;
 				}
 				return originalOpen(url, target, features);
 			};
 		})();
-	` + "`" + `)
+	` + "`" + `, target)
 	runtime.WindowExecJS(ctx, script)
+
+	// 按主题设置背景色
+	runtime.WindowExecJS(ctx, themeScript())
+{{- if .Injects}}
+
+	// 按当前页面应用注入规则
+	runtime.WindowExecJS(ctx, rulesScript())
+{{- end}}
+{{- if .Badge}}
+
+	// 监听标题中的未读计数
+	runtime.WindowExecJS(ctx, badgeScript())
+{{- end}}
 }
 
 func main() {
+{{- if and .Proxy .Proxy.Enabled}}
+	// Apply proxy settings before the webview is created
+	configureProxy()
+{{end}}
+{{- if .Update}}
+	// Check the release feed for updates in the background
+	startUpdater()
+{{end}}{{- if .Rules}}
+	// Use the latest signed injection rules, updated in the background
+	startRules()
+{{else if .Injects}}
+	// Customize pages with the built-in injection rules
+	activeRules.Store(builtinRules())
+{{end}}
+	// Prepare an isolated webview profile
+	dataDir, cleanupProfile := setupProfile()
+
 	// Create an instance of the app structure
 	app := NewApp()
 
 	// Create application with options
 	err := wails.Run(&options.App{
-		Title:             "{{.Name}}",
+		Title:             {{printf "%q" .Name}},
 		Width:            {{.Width}},
 		Height:           {{.Height}},
 		DisableResize:    false,
@@ -479,7 +819,7 @@ func main() {
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 		},
-		BackgroundColour: &options.RGBA{R: 255, G: 255, B: 255, A: 1},
+		BackgroundColour: backgroundColour(),
 		OnStartup:        app.startup,
 		OnDomReady:       app.domReady,
 		Bind: []interface{}{
@@ -489,11 +829,22 @@ func main() {
 			WebviewIsTransparent: false,
 			WindowIsTranslucent:  false,
 			TitleBar:            {{if .HideTitleBar}}mac.TitleBarHidden(){{else}}mac.TitleBarDefault(){{end}},
-			Appearance:          mac.NSAppearanceNameAqua,
+			Appearance:          {{if eq .Theme "dark"}}mac.NSAppearanceNameDarkAqua{{else if eq .Theme "system"}}mac.DefaultAppearance{{else}}mac.NSAppearanceNameAqua{{end}},
+		},
+		Windows: &windows.Options{
+			WebviewUserDataPath: dataDir,
+			Theme:               {{if eq .Theme "dark"}}windows.Dark{{else if eq .Theme "system"}}windows.SystemDefault{{else}}windows.Light{{end}},
+		},
+		Linux: &linux.Options{
+			ProgramName: {{printf "%q" .Name}},
 		},
 		Frameless:   {{.HideTitleBar}},
 		AlwaysOnTop: {{.AlwaysOnTop}},
 	})
+{{- if eq .WindowMode "windows"}}
+	waitForWindows()
+{{- end}}
+	cleanupProfile()
 
 	if err != nil {
 		log.Fatal(err)
@@ -501,11 +852,11 @@ func main() {
 }
 `
 
-const goModTemplate = `module {{if .Name}}github.com/{{.Name}}{{else}}pake-app{{end}}
+const goModTemplate = `module {{if .Name}}github.com/{{packageName .Name}}{{else}}pake-app{{end}}
 
 go 1.21
 
-require github.com/wailsapp/wails/v2 v2.10.1
+require github.com/wailsapp/wails/v2 {{wailsVersion}}
 
 require (
 	github.com/bep/debounce v1.2.1 // indirect
@@ -536,29 +887,59 @@ require (
 )`
 
 const wailsConfigTemplate = `{
-	"name": "{{.Name}}",
-	"outputfilename": "{{.Name}}",
+	"name": {{json .Name}},
+	"outputfilename": {{json .Name}},
 	"frontend:install": "npm install",
 	"frontend:build": "npm run build",
 	"frontend:dev": "npm run dev",
 	"author": {
-		"name": "Pake-Go",
-		"email": "pake-go@example.com"
+		"name": {{json .AuthorName}},
+		"email": {{json .AuthorEmail}}
 	},
 	"info": {
-		"companyName": "Pake-Go",
-		"productName": "{{.Name}}",
-		"productVersion": "1.0.0",
-		"copyright": "Copyright © 2024 Pake-Go",
-		"comments": "Built with Pake-Go"
+		"companyName": {{json .CompanyName}},
+		"productName": {{json .Name}},
+		"productVersion": {{json .NumericVersion}},
+		"copyright": {{json .CopyrightNotice}},
+		"comments": {{json .Description}}
 	}
 }
 `
 
+const infoPlistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>CFBundlePackageType</key>
+		<string>APPL</string>
+		<key>CFBundleName</key>
+		<string>{{html .Name}}</string>
+		<key>CFBundleExecutable</key>
+		<string>{{html .Name}}</string>
+		<key>CFBundleIdentifier</key>
+		<string>{{html .BundleIdentifier}}</string>
+		<key>CFBundleVersion</key>
+		<string>{{html .NumericVersion}}</string>
+		<key>CFBundleShortVersionString</key>
+		<string>{{html .NumericVersion}}</string>
+		<key>CFBundleGetInfoString</key>
+		<string>{{html .Description}}</string>
+		<key>CFBundleIconFile</key>
+		<string>iconfile</string>
+		<key>LSMinimumSystemVersion</key>
+		<string>10.13.0</string>
+		<key>NSHighResolutionCapable</key>
+		<string>true</string>
+		<key>NSHumanReadableCopyright</key>
+		<string>{{html .CopyrightNotice}}</string>
+	</dict>
+</plist>
+`
+
 const packageJSONTemplate = `{
-	"name": "{{.Name}}",
-	"version": "1.0.0",
-	"description": "Built with Pake-Go",
+	"name": {{json (packageName .Name)}},
+	"version": {{json .NumericVersion}},
+	"description": {{json .Description}},
 	"type": "module",
 	"scripts": {
 		"dev": "vite",
@@ -566,11 +947,11 @@ const packageJSONTemplate = `{
 		"preview": "vite preview"
 	},
 	"dependencies": {
-		"vue": "^3.3.0"
+		"vue": "3.3.13"
 	},
 	"devDependencies": {
-		"@vitejs/plugin-vue": "^4.5.0",
-		"vite": "^4.5.0"
+		"@vitejs/plugin-vue": "4.5.2",
+		"vite": "4.5.3"
 	}
 }
 `
@@ -596,7 +977,7 @@ const appVueTemplate = `<template>
 				border: 'none',
 				opacity: isLoading ? 0 : 1,
 				transition: 'opacity 0.3s ease-in-out',
-				background: '#ffffff'
+				background: 'var(--pake-background)'
 			}"
 			ref="frame"
 		></div>
@@ -650,13 +1031,26 @@ export default {
 </script>
 
 <style>
+:root {
+	--pake-background: {{.ThemeBackground}};
+	color-scheme: {{if eq .Theme "dark"}}dark{{else if eq .Theme "system"}}light dark{{else}}light{{end}};
+}
+{{- if eq .Theme "system"}}
+
+@media (prefers-color-scheme: dark) {
+	:root {
+		--pake-background: {{.DarkBackground}};
+	}
+}
+{{- end}}
+
 html, body {
 	margin: 0;
 	padding: 0;
 	width: 100%;
 	height: 100%;
 	overflow: hidden;
-	background: #ffffff !important;
+	background: var(--pake-background) !important;
 }
 
 #app {
@@ -665,7 +1059,7 @@ html, body {
 	margin: 0;
 	padding: 0;
 	overflow: hidden;
-	background: #ffffff;
+	background: var(--pake-background);
 	position: relative;
 }
 
@@ -674,7 +1068,7 @@ html, body {
 	width: 100%;
 	height: 100%;
 	border: none;
-	background: #ffffff;
+	background: var(--pake-background);
 }
 
 .loading {
@@ -683,7 +1077,7 @@ html, body {
 	left: 0;
 	right: 0;
 	bottom: 0;
-	background: #ffffff;
+	background: var(--pake-background);
 	display: flex;
 	align-items: center;
 	justify-content: center;
@@ -728,52 +1122,194 @@ const indexHTMLTemplate = `<!DOCTYPE html>
 const webviewManagerTemplate = `package main
 
 import (
+	"fmt"
+	"maps"
+	"net/textproto"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 )
 
+// Rule customizes the pages its matcher matches
+type Rule struct {
+	// ID identifies the rule; InsertRule assigns one when empty
+	ID          string
+	Description string
+	// Priority orders the rules: rules are applied from the lowest priority
+	// to the highest, then in the order they were added, so CSS, JS and
+	// headers of a later rule take precedence over earlier ones
+	Priority int
+	// Disabled rules are kept but ignored
+	Disabled bool
+	// URL is the pattern of Match
+	URL     string
+	Match   *Matcher
+	CSS     []string
+	JS      []string
+	Headers map[string]string
+
+	seq int
+}
+
 // WebViewManager manages web content customization
 type WebViewManager struct {
-	mu sync.RWMutex
-	rules []struct {
-		URL     string
-		CSS     []string
-		JS      []string
-		Headers map[string]string
-	}
+	mu    sync.RWMutex
+	rules []Rule
+	seq   int
 }
 
 // NewWebViewManager creates a new WebViewManager
 func NewWebViewManager() *WebViewManager {
 	return &WebViewManager{
-		rules: make([]struct {
-			URL     string
-			CSS     []string
-			JS      []string
-			Headers map[string]string
-		}, 0),
+		rules: make([]Rule, 0),
 	}
 }
 
-// AddRule adds a new injection rule
-func (w *WebViewManager) AddRule(url string, css []string, js []string, headers map[string]string) {
+// AddRule adds a new injection rule for the URLs containing url. It is the
+// legacy matching of MatchLegacy; AddMatchedRule selects precisely.
+func (w *WebViewManager) AddRule(url string, css []string, js []string, headers map[string]string) error {
+	_, err := w.InsertRule(Rule{URL: url, CSS: css, JS: js, Headers: headers})
+	return err
+}
+
+// AddMatchedRule adds a new injection rule for the URLs match matches. An
+// invalid matcher, such as a bad regular expression, is reported and the
+// rule is not added.
+func (w *WebViewManager) AddMatchedRule(match *Matcher, css []string, js []string, headers map[string]string) error {
+	_, err := w.InsertRule(Rule{Match: match, CSS: css, JS: js, Headers: headers})
+	return err
+}
+
+// InsertRule adds a rule and returns its ID. A rule without a matcher
+// matches URLs containing its URL, like AddRule. The matcher is validated
+// and, like the other fields, copied, so later changes do not affect the rule.
+func (w *WebViewManager) InsertRule(rule Rule) (string, error) {
+	rule, err := normalizeRule(rule)
+	if err != nil {
+		return "", err
+	}
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.rules = append(w.rules, struct {
-		URL     string
-		CSS     []string
-		JS      []string
-		Headers map[string]string
-	}{
-		URL:     url,
-		CSS:     css,
-		JS:      js,
-		Headers: headers,
+	w.seq++
+	if rule.ID == "" {
+		rule.ID = fmt.Sprintf("rule-%d", w.seq)
+		for w.find(rule.ID) >= 0 {
+			w.seq++
+			rule.ID = fmt.Sprintf("rule-%d", w.seq)
+		}
+	} else if w.find(rule.ID) >= 0 {
+		return "", fmt.Errorf("rule %q already exists", rule.ID)
+	}
+	rule.seq = w.seq
+	w.rules = append(w.rules, rule)
+	w.sortRules()
+	return rule.ID, nil
+}
+
+// UpdateRule replaces the rule with the ID of rule, which keeps its place
+// among rules of the same priority
+func (w *WebViewManager) UpdateRule(rule Rule) error {
+	rule, err := normalizeRule(rule)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.find(rule.ID)
+	if i < 0 {
+		return fmt.Errorf("rule %q not found", rule.ID)
+	}
+	rule.seq = w.rules[i].seq
+	w.rules[i] = rule
+	w.sortRules()
+	return nil
+}
+
+// RemoveRule removes the rule with the given ID and reports whether it
+// existed
+func (w *WebViewManager) RemoveRule(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.find(id)
+	if i < 0 {
+		return false
+	}
+	w.rules = append(w.rules[:i], w.rules[i+1:]...)
+	return true
+}
+
+// ListRules returns copies of the rules in the order they are applied
+func (w *WebViewManager) ListRules() []Rule {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	rules := make([]Rule, len(w.rules))
+	for i, rule := range w.rules {
+		rules[i] = copyRule(rule)
+	}
+	return rules
+}
+
+// find returns the index of the rule with the given ID, or -1
+func (w *WebViewManager) find(id string) int {
+	for i, rule := range w.rules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortRules orders the rules by priority, then by the order they were added
+func (w *WebViewManager) sortRules() {
+	sort.Slice(w.rules, func(i, j int) bool {
+		if w.rules[i].Priority != w.rules[j].Priority {
+			return w.rules[i].Priority < w.rules[j].Priority
+		}
+		return w.rules[i].seq < w.rules[j].seq
 	})
 }
 
-// GetRulesForURL returns all matching rules for a given URL
+// normalizeRule returns a copy of rule with a compiled matcher, a legacy one
+// for a rule without a matcher, and URL in sync with it
+func normalizeRule(rule Rule) (Rule, error) {
+	match := rule.Match
+	if match == nil {
+		match = &Matcher{Type: MatchLegacy, Pattern: rule.URL}
+	}
+	match, err := match.compile()
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid matcher: %w", err)
+	}
+	rule = copyRule(rule)
+	rule.Match = match
+	rule.URL = match.Pattern
+	return rule, nil
+}
+
+// copyRule returns a copy of rule that shares no slices, maps or matcher
+// with it
+func copyRule(rule Rule) Rule {
+	if rule.Match != nil {
+		match := *rule.Match
+		match.Query = maps.Clone(match.Query)
+		rule.Match = &match
+	}
+	rule.CSS = slices.Clone(rule.CSS)
+	rule.JS = slices.Clone(rule.JS)
+	rule.Headers = maps.Clone(rule.Headers)
+	return rule
+}
+
+// GetRulesForURL returns the CSS, JS and headers of the enabled rules
+// matching a given URL, in the order of the rules. Header names are compared
+// case-insensitively and the last rule setting a header wins.
 func (w *WebViewManager) GetRulesForURL(url string) ([]string, []string, map[string]string) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -783,11 +1319,11 @@ func (w *WebViewManager) GetRulesForURL(url string) ([]string, []string, map[str
 	headers := make(map[string]string)
 
 	for _, rule := range w.rules {
-		if strings.Contains(url, rule.URL) {
+		if !rule.Disabled && rule.Match.Match(url) {
 			css = append(css, rule.CSS...)
 			js = append(js, rule.JS...)
 			for k, v := range rule.Headers {
-				headers[k] = v
+				headers[textproto.CanonicalMIMEHeaderKey(k)] = v
 			}
 		}
 	}
@@ -800,12 +1336,7 @@ func (w *WebViewManager) ClearRules() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.rules = make([]struct {
-		URL     string
-		CSS     []string
-		JS      []string
-		Headers map[string]string
-	}, 0)
+	w.rules = make([]Rule, 0)
 }
 
 // GenerateInjectionScript generates the JavaScript code for injecting CSS and JS
@@ -817,7 +1348,7 @@ func (w *WebViewManager) GenerateInjectionScript(css []string, js []string) stri
 		// Escape single quotes and backslashes
 		escapedStyle := strings.ReplaceAll(style, "\\", "\\\\")
 		escapedStyle = strings.ReplaceAll(escapedStyle, "'", "\\'")
-		
+
 		script.WriteString("(function() {")
 		script.WriteString("var style = document.createElement('style');")
 		script.WriteString("style.textContent = '" + escapedStyle + "';")
@@ -835,3 +1366,221 @@ func (w *WebViewManager) GenerateInjectionScript(css []string, js []string) stri
 	return script.String()
 }
 `
+
+const matchTemplate = `package main
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MatchType selects how a rule is matched against page URLs
+type MatchType string
+
+// Match types
+const (
+	// MatchExact matches the URL equal to the pattern
+	MatchExact MatchType = "exact"
+	// MatchPrefix matches URLs starting with the pattern
+	MatchPrefix MatchType = "prefix"
+	// MatchGlob matches URLs against a pattern such as *.example.com/app/*,
+	// see NewMatcher
+	MatchGlob MatchType = "glob"
+	// MatchRegex matches URLs the regular expression pattern matches
+	MatchRegex MatchType = "regex"
+	// MatchURL matches the parsed host, path and query, see NewURLMatcher
+	MatchURL MatchType = "url"
+	// MatchLegacy matches URLs containing the pattern anywhere. It matches
+	// more pages than intended and is kept for rules written for it.
+	MatchLegacy MatchType = "legacy"
+)
+
+// Matcher decides which pages a rule applies to. Matchers are created with
+// NewMatcher or NewURLMatcher; a literal only matches once it is compiled by
+// InsertRule or UpdateRule.
+type Matcher struct {
+	Type    MatchType
+	Pattern string
+	// Host, Path and Query are matched by MatchURL
+	Host  string
+	Path  string
+	Query map[string]string
+
+	host *regexp.Regexp
+	path *regexp.Regexp
+	re   *regexp.Regexp
+	// port is set for glob hosts with a port, which are matched against the
+	// host and port of the URL
+	port bool
+	// compiled is set by the constructors
+	compiled bool
+}
+
+// NewMatcher returns a matcher of the given type for pattern.
+//
+// Glob patterns are a host with an optional scheme, port and path, where *
+// matches any characters within the host or the path and ? a single one. A
+// host starting with *. also matches the domain itself, so
+// *.example.com/app/* matches https://example.com/app/ and
+// https://www.example.com/app/x?y=1 but not
+// https://evil.net/?x.example.com/app/. A host without a port matches any
+// port.
+func NewMatcher(typ MatchType, pattern string) (*Matcher, error) {
+	m := &Matcher{Type: typ, Pattern: pattern, compiled: true}
+	switch typ {
+	case MatchExact, MatchPrefix, MatchLegacy:
+	case MatchGlob:
+		scheme, rest, ok := strings.Cut(pattern, "://")
+		if !ok {
+			scheme, rest = "", pattern
+		}
+		host, path, _ := strings.Cut(rest, "/")
+		if host == "" {
+			return nil, fmt.Errorf("glob %q has no host", pattern)
+		}
+		m.Host = host
+		if scheme != "" {
+			m.Host = scheme + "://" + host
+		}
+		m.host = compileHostGlob(m.Host)
+		m.port = strings.Contains(host, ":")
+		if path != "" {
+			m.path = compileGlob("/"+path, ".*")
+		}
+	case MatchRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+		m.re = re
+	case MatchURL:
+		return nil, fmt.Errorf("url matchers are created with NewURLMatcher")
+	default:
+		return nil, fmt.Errorf("unknown match type %q", typ)
+	}
+	return m, nil
+}
+
+// NewURLMatcher returns a matcher of parsed URLs. host is a host name, where
+// *.example.com also matches example.com and its subdomains; path is a glob
+// matched against the whole path; every query parameter must have the given
+// value, or be present when the value is empty. Empty host and path match
+// any.
+func NewURLMatcher(host string, path string, query map[string]string) (*Matcher, error) {
+	if strings.ContainsAny(host, "/:") {
+		return nil, fmt.Errorf("invalid host %q", host)
+	}
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q does not start with /", path)
+	}
+	m := &Matcher{Type: MatchURL, Host: host, Path: path, Query: maps.Clone(query), compiled: true}
+	if host != "" {
+		m.host = compileHostGlob(host)
+	}
+	if path != "" {
+		m.path = compileGlob(path, ".*")
+	}
+	return m, nil
+}
+
+// compile returns a matcher created from the exported fields of m
+func (m *Matcher) compile() (*Matcher, error) {
+	if m.Type == MatchURL {
+		return NewURLMatcher(m.Host, m.Path, m.Query)
+	}
+	return NewMatcher(m.Type, m.Pattern)
+}
+
+// compileHostGlob compiles a host glob, optionally with a scheme
+func compileHostGlob(host string) *regexp.Regexp {
+	scheme, name, ok := strings.Cut(strings.ToLower(host), "://")
+	if !ok {
+		scheme, name = "", scheme
+	}
+	expr := "^"
+	if scheme != "" {
+		expr += regexp.QuoteMeta(scheme + "://")
+	}
+	if rest, ok := strings.CutPrefix(name, "*."); ok {
+		expr += ` + "`" + `(?:[^/]*\.)?` + "`" + `
+		name = rest
+	}
+	return regexp.MustCompile(expr + globExpr(name, "[^/]*") + "$")
+}
+
+// compileGlob compiles a glob whose * matches star, anchored at both ends
+func compileGlob(glob string, star string) *regexp.Regexp {
+	return regexp.MustCompile("^" + globExpr(glob, star) + "$")
+}
+
+// globExpr returns the regular expression of a glob whose * matches star
+func globExpr(glob string, star string) string {
+	var expr strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(star)
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return expr.String()
+}
+
+// Match reports whether the rule applies to the page at rawURL. A matcher
+// that was not compiled matches nothing.
+func (m *Matcher) Match(rawURL string) bool {
+	if m == nil || !m.compiled {
+		return false
+	}
+	switch m.Type {
+	case MatchExact:
+		return rawURL == m.Pattern
+	case MatchPrefix:
+		return strings.HasPrefix(rawURL, m.Pattern)
+	case MatchRegex:
+		return m.re.MatchString(rawURL)
+	case MatchLegacy:
+		return strings.Contains(rawURL, m.Pattern)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if m.port {
+		host = strings.ToLower(u.Host)
+	}
+	if m.Type == MatchGlob && strings.Contains(m.Host, "://") {
+		host = strings.ToLower(u.Scheme) + "://" + host
+	}
+	if m.host != nil && !m.host.MatchString(host) {
+		return false
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if m.Type == MatchGlob && u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if m.path != nil && !m.path.MatchString(path) {
+		return false
+	}
+
+	query := u.Query()
+	for key, value := range m.Query {
+		if !query.Has(key) || (value != "" && query.Get(key) != value) {
+			return false
+		}
+	}
+	return true
+}
+`
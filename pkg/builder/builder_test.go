@@ -0,0 +1,274 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/manifest"
+)
+
+// parseGoFiles checks that every generated Go file in dir is syntactically valid
+func parseGoFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatalf("Failed to list generated files: %v", err)
+	}
+	if len(matches) == 0 {
+		t.Fatalf("No Go files generated in %s", dir)
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range matches {
+		if _, err := parser.ParseFile(fset, path, nil, parser.AllErrors); err != nil {
+			t.Errorf("Generated file %s is invalid: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestGenerateMainGo(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.URL = "https://test.com"
+	cfg.Name = "TestApp"
+
+	projectDir := t.TempDir()
+	if err := NewBuilder(cfg).generateMainGo(projectDir); err != nil {
+		t.Fatalf("Failed to generate main.go: %v", err)
+	}
+	parseGoFiles(t, projectDir)
+
+	if _, err := os.Stat(filepath.Join(projectDir, "badge.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected no badge.go without badge config")
+	}
+}
+
+func TestGenerateBadge(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.URL = "https://test.com"
+	cfg.Name = `Test "App"`
+	cfg.Badge = &config.BadgeConfig{Title: true}
+
+	projectDir := t.TempDir()
+	if err := NewBuilder(cfg).generateMainGo(projectDir); err != nil {
+		t.Fatalf("Failed to generate main.go: %v", err)
+	}
+	parseGoFiles(t, projectDir)
+
+	mainGo, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to read main.go: %v", err)
+	}
+	if !strings.Contains(string(mainGo), "badgeScript()") {
+		t.Errorf("Expected domReady to inject the badge script")
+	}
+
+	badgeGo, err := os.ReadFile(filepath.Join(projectDir, "badge.go"))
+	if err != nil {
+		t.Fatalf("Failed to read badge.go: %v", err)
+	}
+	if !strings.Contains(string(badgeGo), `"^\\((\\d+)\\)"`) {
+		t.Errorf("Expected default badge pattern in badge.go, got:\n%s", badgeGo)
+	}
+}
+
+func TestGenerateWindowMode(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.URL = "https://test.com/?q=a%20b"
+	cfg.Name = "TestApp"
+	cfg.WindowMode = config.WindowModeWindows
+
+	projectDir := t.TempDir()
+	if err := NewBuilder(cfg).generateMainGo(projectDir); err != nil {
+		t.Fatalf("Failed to generate main.go: %v", err)
+	}
+	parseGoFiles(t, projectDir)
+
+	if _, err := os.Stat(filepath.Join(projectDir, "windows.go")); err != nil {
+		t.Errorf("Expected windows.go for windows mode: %v", err)
+	}
+}
+
+func TestProxySettings(t *testing.T) {
+	proxy := &config.ProxyConfig{
+		URL:    "socks5://127.0.0.1:1080",
+		Bypass: []string{"localhost", "*.corp.example.com"},
+	}
+
+	args := webview2ProxyArgs(proxy)
+	if args != "--proxy-server=socks5://127.0.0.1:1080 --proxy-bypass-list=localhost;*.corp.example.com" {
+		t.Errorf("Unexpected WebView2 args: %s", args)
+	}
+
+	env := proxyEnv(proxy)
+	if env["all_proxy"] != proxy.URL {
+		t.Errorf("Expected all_proxy for socks proxy, got %q", env["all_proxy"])
+	}
+	if env["no_proxy"] != "localhost,.corp.example.com" {
+		t.Errorf("Unexpected no_proxy: %q", env["no_proxy"])
+	}
+
+	proxy.PAC = "http://pac.corp.example.com/proxy.pac"
+	if args := webview2ProxyArgs(proxy); !strings.HasPrefix(args, "--proxy-pac-url=") {
+		t.Errorf("Expected PAC to take precedence, got %s", args)
+	}
+}
+
+func TestRGBALiteral(t *testing.T) {
+	tests := map[string]string{
+		"#ffffff": "&options.RGBA{R: 255, G: 255, B: 255, A: 255}",
+		"#1e1e1e": "&options.RGBA{R: 30, G: 30, B: 30, A: 255}",
+		"#123":    "&options.RGBA{R: 17, G: 34, B: 51, A: 255}",
+	}
+
+	for color, want := range tests {
+		if got := rgbaLiteral(color); got != want {
+			t.Errorf("rgbaLiteral(%q) = %s, want %s", color, got, want)
+		}
+	}
+}
+
+func TestGenerateWailsConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Name = "TestApp"
+	cfg.Version = "2.1.0"
+	cfg.Company = "ACME"
+	cfg.Identifier = "com.acme.testapp"
+
+	projectDir := t.TempDir()
+	if err := NewBuilder(cfg).generateWailsConfig(projectDir); err != nil {
+		t.Fatalf("Failed to generate wails.json: %v", err)
+	}
+
+	var wails struct {
+		Info struct {
+			CompanyName    string `json:"companyName"`
+			ProductVersion string `json:"productVersion"`
+		} `json:"info"`
+	}
+	data, err := os.ReadFile(filepath.Join(projectDir, "wails.json"))
+	if err != nil {
+		t.Fatalf("Failed to read wails.json: %v", err)
+	}
+	if err := json.Unmarshal(data, &wails); err != nil {
+		t.Fatalf("Generated wails.json is invalid: %v", err)
+	}
+	if wails.Info.CompanyName != "ACME" || wails.Info.ProductVersion != "2.1.0" {
+		t.Errorf("Unexpected wails.json info: %+v", wails.Info)
+	}
+
+	plist, err := os.ReadFile(filepath.Join(projectDir, "build", "darwin", "Info.plist"))
+	if err != nil {
+		t.Fatalf("Failed to read Info.plist: %v", err)
+	}
+	if !strings.Contains(string(plist), "<string>com.acme.testapp</string>") {
+		t.Errorf("Expected bundle identifier in Info.plist")
+	}
+	if strings.Contains(string(data)+string(plist), "Pake-Go") {
+		t.Errorf("Expected no Pake-Go branding in app metadata")
+	}
+}
+
+func TestWarnUnsupportedProfile(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Incognito = true
+	cfg.DataDir = "/tmp/profile"
+	rec := &recorder{}
+	b := NewBuilder(cfg)
+	b.Reporter = rec
+
+	b.warnUnsupportedProfile(target{platform: "linux/amd64"})
+	if len(rec.events) != 0 {
+		t.Errorf("Expected no warning for Linux, got %v", rec.events)
+	}
+	b.warnUnsupportedProfile(target{platform: "darwin/universal"})
+	if len(rec.events) != 2 || rec.events[0].Type != EventWarning || !strings.Contains(rec.events[0].Message, "incognito") {
+		t.Errorf("Expected warnings about incognito and dataDir on macOS, got %v", rec.events)
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not in PATH")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	writeFiles(t, dir, map[string]string{"README": "app"})
+	git("add", "README")
+	git("commit", "-q", "-m", "initial")
+
+	resolve := func() (string, []Event) {
+		cfg := config.DefaultConfig()
+		cfg.Version = config.VersionFromGit
+		rec := &recorder{}
+		b := NewBuilder(cfg)
+		b.Reporter = rec
+		if err := b.resolveVersion(context.Background()); err != nil {
+			t.Fatalf("Failed to resolve the version: %v", err)
+		}
+		return cfg.Version, rec.events
+	}
+
+	// Without a tag the commit hash is not used as the version
+	if version, events := resolve(); version != config.DefaultVersion || len(events) != 1 || events[0].Type != EventWarning {
+		t.Errorf("Expected %s with a warning without a tag, got %q, %v", config.DefaultVersion, version, events)
+	}
+
+	git("tag", "v1.2.0")
+	if version, events := resolve(); version != "1.2.0" || len(events) != 0 {
+		t.Errorf("Expected 1.2.0 from the tag, got %q, %v", version, events)
+	}
+	writeFiles(t, dir, map[string]string{"README": "changed"})
+	if version, _ := resolve(); version != "1.2.0-dirty" {
+		t.Errorf("Expected 1.2.0-dirty for local changes, got %q", version)
+	}
+}
+
+func TestGoModModulePath(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not in PATH")
+	}
+
+	// Manifest names are display names with spaces
+	m, err := manifest.Parse([]byte(`{"name": "My App", "start_url": "https://example.com/"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	m.Apply(cfg, nil)
+
+	projectDir := t.TempDir()
+	if err := NewBuilder(cfg).writeProjectFile(projectDir, "go.mod"); err != nil {
+		t.Fatalf("Failed to generate go.mod: %v", err)
+	}
+	cmd := exec.Command("go", "mod", "edit", "-json")
+	cmd.Dir = projectDir
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Generated go.mod is invalid: %v\n%s", err, out)
+	}
+	var mod struct{ Module struct{ Path string } }
+	if err := json.Unmarshal(out, &mod); err != nil {
+		t.Fatal(err)
+	}
+	if mod.Module.Path != "github.com/my-app" {
+		t.Errorf("Expected module github.com/my-app, got %q", mod.Module.Path)
+	}
+}
@@ -0,0 +1,316 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// cacheVersion is bumped whenever the cache layout or key changes
+const cacheVersion = "1"
+
+// cacheMaxAge is how long cache entries are kept after their last use
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// frontendCacheFiles are the npm install results reused between builds
+var frontendCacheFiles = []string{"node_modules", "package-lock.json", "package.json.md5"}
+
+// buildCache stores built binaries keyed on the rendered project and keeps
+// node_modules between builds
+type buildCache struct {
+	dir       string
+	toolchain string
+}
+
+// newBuildCache creates a build cache in dir for builds made with toolchain.
+// An empty dir uses the user cache dir.
+func newBuildCache(dir string, toolchain Toolchain) (*buildCache, error) {
+	dir, err := cacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &buildCache{
+		dir:       dir,
+		toolchain: toolchain.String() + "\n" + runtime.GOOS + "/" + runtime.GOARCH,
+	}, nil
+}
+
+// key hashes every file of the rendered project together with the toolchain
+// and the build variant, such as the target platform
+func (c *buildCache) key(projectDir string, variant string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "pake-go build cache %s\n%s\n%s\n", cacheVersion, c.toolchain, variant)
+	if err := hashTree(h, projectDir); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheDir returns dir, or the default cache directory when it is empty
+func cacheDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "pake-go"), nil
+}
+
+// CleanCache removes the entries of the build cache in dir, the user cache
+// dir when empty, that were not used for maxAge, or every entry when maxAge
+// is 0. It returns the removed entries.
+func CleanCache(dir string, maxAge time.Duration) ([]string, error) {
+	dir, err := cacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return (&buildCache{dir: dir}).evict(maxAge)
+}
+
+// evict removes the cached builds and node_modules not used for maxAge
+func (c *buildCache) evict(maxAge time.Duration) ([]string, error) {
+	var removed []string
+	for _, kind := range []string{"builds", "node_modules"} {
+		entries, err := os.ReadDir(filepath.Join(c.dir, kind))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if maxAge > 0 && time.Since(info.ModTime()) < maxAge {
+				continue
+			}
+			path := filepath.Join(c.dir, kind, entry.Name())
+			if err := os.RemoveAll(path); err != nil {
+				return removed, err
+			}
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
+
+// touch marks a cache entry as used now
+func touch(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// hashTree writes the path, mode and content of every file below root to h,
+// skipping build outputs and installed dependencies
+func hashTree(h io.Writer, root string) error {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			switch rel {
+			case "build/bin", "frontend/node_modules", "frontend/dist":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel == "frontend/package.json.md5" || rel == "frontend/package-lock.json" {
+			return nil
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(paths)
+	for _, rel := range paths {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %o %d\n", rel, info.Mode().Perm(), info.Size())
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(h, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// binDir returns the cached build output for key
+func (c *buildCache) binDir(key string) string {
+	return filepath.Join(c.dir, "builds", key, "bin")
+}
+
+// lookup copies the cached build output for key into dst and reports whether
+// there was one
+func (c *buildCache) lookup(key string, dst string) (bool, error) {
+	src := c.binDir(key)
+	if _, err := os.Stat(src); err != nil {
+		return false, nil
+	}
+	touch(filepath.Dir(src))
+	if err := os.RemoveAll(dst); err != nil {
+		return false, err
+	}
+	if err := copyTree(src, dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// store copies the build output in src into the cache under key
+func (c *buildCache) store(key string, src string) error {
+	dst := c.binDir(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Copy next to the final location first so readers never see a partial entry
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "bin-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := copyTree(src, filepath.Join(tmp, "bin")); err != nil {
+		return err
+	}
+	os.RemoveAll(dst)
+	return os.Rename(filepath.Join(tmp, "bin"), dst)
+}
+
+// frontendCache returns the cache directory for the npm install results of
+// frontendDir, keyed on package.json and a locked package-lock.json
+func (c *buildCache) frontendCache(frontendDir string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", c.toolchain)
+	for _, name := range []string{"package.json", "package-lock.json"} {
+		data, err := os.ReadFile(filepath.Join(frontendDir, name))
+		if os.IsNotExist(err) && name == "package-lock.json" {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+	}
+	return filepath.Join(c.dir, "node_modules", hex.EncodeToString(h.Sum(nil))[:16]), nil
+}
+
+// restoreFrontend copies the npm install results cached in cached into
+// frontendDir so that wails build skips npm install. The cache entry is left
+// in place for concurrent builds. Files that already exist, such as a locked
+// package-lock.json, are kept.
+func (c *buildCache) restoreFrontend(cached string, frontendDir string) error {
+	if _, err := os.Stat(cached); err != nil {
+		return nil
+	}
+	touch(cached)
+
+	for _, name := range frontendCacheFiles {
+		src := filepath.Join(cached, name)
+		dst := filepath.Join(frontendDir, name)
+		info, err := os.Lstat(src)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		if !info.IsDir() {
+			if err := copyFile(src, dst, info.Mode().Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Copy next to the final location first so a failed copy leaves no
+		// partial node_modules
+		tmp, err := os.MkdirTemp(frontendDir, name+"-")
+		if err != nil {
+			return err
+		}
+		err = copyTree(src, filepath.Join(tmp, name))
+		if err == nil {
+			err = os.Rename(filepath.Join(tmp, name), dst)
+		}
+		os.RemoveAll(tmp)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveFrontend moves node_modules of frontendDir into a new cache entry
+// cached. The other npm install results are copied, as they stay part of
+// the project. An existing entry is kept: it holds the install results of
+// the same package.json, possibly in use by a concurrent build.
+func (c *buildCache) saveFrontend(frontendDir string, cached string) error {
+	if _, err := os.Stat(cached); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+		return err
+	}
+
+	// Fill the entry next to its final location so readers never see a
+	// partial entry
+	tmp, err := os.MkdirTemp(filepath.Dir(cached), "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, name := range frontendCacheFiles {
+		src := filepath.Join(frontendDir, name)
+		info, err := os.Lstat(src)
+		if err != nil {
+			continue
+		}
+		dst := filepath.Join(tmp, name)
+		if info.IsDir() {
+			err = moveAll(src, dst)
+		} else {
+			err = copyFile(src, dst, info.Mode().Perm())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// A concurrent build may have saved the entry in the meantime
+	if err := os.Rename(tmp, cached); err != nil {
+		if _, statErr := os.Stat(cached); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildCacheKey(t *testing.T) {
+	cache := &buildCache{dir: t.TempDir(), toolchain: "go version go1.22"}
+	projectDir := t.TempDir()
+	writeFiles(t, projectDir, map[string]string{
+		"main.go":               "package main",
+		"frontend/package.json": "{}",
+	})
+
+	key, err := cache.key(projectDir, "")
+	if err != nil {
+		t.Fatalf("Failed to hash project: %v", err)
+	}
+
+	// Build outputs and installed dependencies do not affect the key
+	writeFiles(t, projectDir, map[string]string{
+		"build/bin/app":                  "binary",
+		"frontend/node_modules/vue/x.js": "x",
+		"frontend/package.json.md5":      "abc",
+	})
+	if again, _ := cache.key(projectDir, ""); again != key {
+		t.Errorf("Expected key to ignore build outputs")
+	}
+
+	if cross, _ := cache.key(projectDir, "linux/arm64"); cross == key {
+		t.Errorf("Expected key to change with the platform")
+	}
+
+	writeFiles(t, projectDir, map[string]string{"main.go": "package main // changed"})
+	if changed, _ := cache.key(projectDir, ""); changed == key {
+		t.Errorf("Expected key to change with the project")
+	}
+
+	cache.toolchain = "go version go1.23"
+	writeFiles(t, projectDir, map[string]string{"main.go": "package main"})
+	if upgraded, _ := cache.key(projectDir, ""); upgraded == key {
+		t.Errorf("Expected key to change with the toolchain")
+	}
+}
+
+func TestBuildCacheLookup(t *testing.T) {
+	cache := &buildCache{dir: t.TempDir()}
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"MyApp": "binary"})
+
+	dst := filepath.Join(t.TempDir(), "bin")
+	if hit, err := cache.lookup("abc", dst); err != nil || hit {
+		t.Fatalf("Expected cache miss, got %v, %v", hit, err)
+	}
+
+	if err := cache.store("abc", src); err != nil {
+		t.Fatalf("Failed to store build: %v", err)
+	}
+	if hit, err := cache.lookup("abc", dst); err != nil || !hit {
+		t.Fatalf("Expected cache hit, got %v, %v", hit, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "MyApp"))
+	if err != nil || string(data) != "binary" {
+		t.Errorf("Expected cached binary, got %q, %v", data, err)
+	}
+}
+
+func TestBuildCacheFrontend(t *testing.T) {
+	cache := &buildCache{dir: t.TempDir()}
+	frontendDir := t.TempDir()
+	writeFiles(t, frontendDir, map[string]string{
+		"package.json":              "{}",
+		"package.json.md5":          "abc",
+		"node_modules/vue/index.js": "vue",
+	})
+
+	cached, err := cache.frontendCache(frontendDir)
+	if err != nil {
+		t.Fatalf("Failed to key node_modules: %v", err)
+	}
+	if err := cache.saveFrontend(frontendDir, cached); err != nil {
+		t.Fatalf("Failed to save node_modules: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(frontendDir, "node_modules")); !os.IsNotExist(err) {
+		t.Errorf("Expected node_modules to move into the cache")
+	}
+
+	// A fresh project with the same package.json reuses node_modules
+	frontendDir = t.TempDir()
+	writeFiles(t, frontendDir, map[string]string{"package.json": "{}"})
+	if again, _ := cache.frontendCache(frontendDir); again != cached {
+		t.Fatalf("Expected the same cache entry for the same package.json")
+	}
+	if err := cache.restoreFrontend(cached, frontendDir); err != nil {
+		t.Fatalf("Failed to restore node_modules: %v", err)
+	}
+	for _, name := range []string{"package.json.md5", "node_modules/vue/index.js"} {
+		if _, err := os.Stat(filepath.Join(frontendDir, name)); err != nil {
+			t.Errorf("Expected %s to be restored: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(cached, name)); err != nil {
+			t.Errorf("Expected %s to stay in the cache: %v", name, err)
+		}
+	}
+
+	// Saving again keeps the existing entry, which concurrent builds may use
+	writeFiles(t, frontendDir, map[string]string{"node_modules/vue/index.js": "changed"})
+	if err := cache.saveFrontend(frontendDir, cached); err != nil {
+		t.Fatalf("Failed to save node_modules: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cached, "node_modules/vue/index.js")); string(data) != "vue" {
+		t.Errorf("Expected the existing cache entry to be kept, got %q", data)
+	}
+
+	// A locked package-lock.json selects its own node_modules
+	writeFiles(t, frontendDir, map[string]string{"package-lock.json": "{}"})
+	if locked, _ := cache.frontendCache(frontendDir); locked == cached {
+		t.Errorf("Expected package-lock.json to change the cache entry")
+	}
+}
+
+func TestBuildCacheEvict(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"builds/old/bin/app":                "old",
+		"builds/new/bin/app":                "new",
+		"node_modules/old/package.json.md5": "old",
+		"node_modules/new/package.json.md5": "new",
+	})
+	old := time.Now().Add(-2 * cacheMaxAge)
+	for _, kind := range []string{"builds", "node_modules"} {
+		if err := os.Chtimes(filepath.Join(dir, kind, "old"), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A lookup marks the build as used
+	cache := &buildCache{dir: dir}
+	if err := os.Chtimes(filepath.Join(dir, "builds", "new"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.lookup("new", filepath.Join(t.TempDir(), "app")); err != nil {
+		t.Fatalf("Failed to look up the build: %v", err)
+	}
+
+	removed, err := CleanCache(dir, cacheMaxAge)
+	if err != nil {
+		t.Fatalf("Failed to clean the cache: %v", err)
+	}
+	want := []string{filepath.Join(dir, "builds", "old"), filepath.Join(dir, "node_modules", "old")}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("Expected %v to be removed, got %v", want, removed)
+	}
+	for _, kind := range []string{"builds", "node_modules"} {
+		if _, err := os.Stat(filepath.Join(dir, kind, "new")); err != nil {
+			t.Errorf("Expected the recent %s entry to be kept: %v", kind, err)
+		}
+	}
+
+	// Without a maximum age every entry is removed
+	if removed, err := CleanCache(dir, 0); err != nil || len(removed) != 2 {
+		t.Errorf("Expected the remaining entries to be removed, got %v, %v", removed, err)
+	}
+}
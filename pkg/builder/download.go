@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"strings"
+
+	"github.com/zk3151463/pake-go/pkg/signing"
+)
+
+// publicKeyLine returns the key line of a public key file, which generated
+// apps verify signed downloads with
+func publicKeyLine(path string) (string, error) {
+	key, err := signing.LoadPublicKey(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(string(key.Encode())), "\n")
+	return lines[len(lines)-1], nil
+}
+
+const downloadTemplate = `package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// downloadClient fetches updates and rules
+var downloadClient = &http.Client{Timeout: 10 * time.Minute}
+
+// maxDownloadBytes limits the size of downloaded feeds, rules and binaries
+const maxDownloadBytes = 512 << 20
+
+// download returns the body of url
+func download(url string) ([]byte, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownloadBytes {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, maxDownloadBytes)
+	}
+	return data, nil
+}
+
+// verifySignature checks a detached minisign style signature of data made
+// with publicKey, the key line of a public key file, and returns its
+// trusted comment
+func verifySignature(publicKey string, data []byte, signature string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != 2+8+ed25519.PublicKeySize {
+		return "", errors.New("invalid public key")
+	}
+
+	lines := strings.Split(strings.TrimRight(signature, "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("malformed signature")
+	}
+	sigLine, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sigLine) != 2+8+ed25519.SignatureSize || !bytes.Equal(sigLine[:2], key[:2]) {
+		return "", errors.New("malformed signature")
+	}
+	if !bytes.Equal(sigLine[2:10], key[2:10]) {
+		return "", errors.New("signed with a different key")
+	}
+	sig := sigLine[10:]
+	if !ed25519.Verify(key[10:], data, sig) {
+		return "", errors.New("signature does not match")
+	}
+
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || !ed25519.Verify(key[10:], append(append([]byte{}, sig...), comment...), globalSig) {
+		return "", errors.New("signature comment does not match")
+	}
+	return comment, nil
+}
+
+// commentField returns the value of a key:value field of a trusted comment,
+// whose fields are separated by tabs
+func commentField(comment string, key string) string {
+	for _, field := range strings.Split(comment, "\t") {
+		if value, ok := strings.CutPrefix(field, key+":"); ok {
+			return value
+		}
+	}
+	return ""
+}
+`
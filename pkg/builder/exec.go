@@ -0,0 +1,23 @@
+package builder
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// killGracePeriod is how long a cancelled command may keep its output pipes
+// open after its process group was killed
+const killGracePeriod = 5 * time.Second
+
+// command creates a command that kills its whole process tree, including the
+// node and go processes started by wails, when ctx is done
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = killGracePeriod
+	return cmd
+}
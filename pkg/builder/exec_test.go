@@ -0,0 +1,66 @@
+//go:build !windows
+
+package builder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestCommandKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The grandchild keeps stdout open, so Wait only returns once it is killed too
+	cmd := command(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	var out strings.Builder
+	cmd.Stdout = &out
+
+	start := time.Now()
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Expected the command to be killed")
+	}
+	if elapsed := time.Since(start); elapsed > killGracePeriod {
+		t.Errorf("Expected the process group to be killed, took %s", elapsed)
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	b := NewBuilder(config.DefaultConfig())
+	b.StepTimeout = 50 * time.Millisecond
+
+	err := b.step(context.Background(), "wails build", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err == nil || !strings.Contains(err.Error(), "wails build: timed out after 50ms") {
+		t.Errorf("Expected step timeout error, got %v", err)
+	}
+}
+
+func TestBuildContextCancelled(t *testing.T) {
+	t.Chdir(t.TempDir())
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	cfg := config.DefaultConfig()
+	cfg.URL = "https://example.com"
+	cfg.Name = "TestApp"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewBuilder(cfg).BuildContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancellation error, got %v", err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("Expected project directory to be removed, found %s", entries[0].Name())
+	}
+}
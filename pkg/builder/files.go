@@ -0,0 +1,108 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// installApp moves the built application to finalAppPath. The new app is
+// staged next to the final location and swapped in with renames, so the
+// previous app is only removed once the new one is in place.
+func installApp(builtAppPath string, finalAppPath string) error {
+	dir := filepath.Dir(finalAppPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create final directory: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(dir, ".install-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	// Stage the new app on the same filesystem as the final location
+	newApp := filepath.Join(staging, "new")
+	if err := moveAll(builtAppPath, newApp); err != nil {
+		return fmt.Errorf("failed to stage built app: %w", err)
+	}
+
+	// Move the existing app aside, it is removed with the staging directory
+	oldApp := ""
+	if _, err := os.Lstat(finalAppPath); err == nil {
+		oldApp = filepath.Join(staging, "old")
+		if err := os.Rename(finalAppPath, oldApp); err != nil {
+			return fmt.Errorf("failed to move existing app aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(newApp, finalAppPath); err != nil {
+		if oldApp != "" {
+			os.Rename(oldApp, finalAppPath)
+		}
+		return fmt.Errorf("failed to move built app: %w", err)
+	}
+
+	return nil
+}
+
+// moveAll moves the file or directory src to dst, copying it when a rename
+// is not possible, e.g. across filesystems
+func moveAll(src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies the directory src to dst, preserving file modes and symlinks
+func copyTree(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// copyFile copies the regular file src to dst with the given mode
+func copyFile(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,39 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallApp(t *testing.T) {
+	dir := t.TempDir()
+	finalAppPath := filepath.Join(dir, "build", "bin")
+	writeFiles(t, finalAppPath, map[string]string{"MyApp": "old"})
+
+	// A failed build leaves the previous app in place
+	if err := installApp(filepath.Join(dir, "missing"), finalAppPath); err == nil {
+		t.Fatal("Expected error for missing build output")
+	}
+	if data, _ := os.ReadFile(filepath.Join(finalAppPath, "MyApp")); string(data) != "old" {
+		t.Errorf("Expected previous app to be kept, got %q", data)
+	}
+
+	builtAppPath := filepath.Join(dir, "work", "build", "bin")
+	writeFiles(t, builtAppPath, map[string]string{"MyApp": "new"})
+	if err := installApp(builtAppPath, finalAppPath); err != nil {
+		t.Fatalf("Failed to install app: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(finalAppPath, "MyApp")); string(data) != "new" {
+		t.Errorf("Expected new app, got %q", data)
+	}
+
+	// No staging directories are left behind
+	entries, err := os.ReadDir(filepath.Dir(finalAppPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the app directory, got %d entries", len(entries))
+	}
+}
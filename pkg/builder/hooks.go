@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+// Build phases that run hooks
+const (
+	HookPreGenerate  = "pre-generate"
+	HookPostGenerate = "post-generate"
+	HookPreSign      = "pre-sign"
+	HookPostBuild    = "post-build"
+)
+
+// HookInput is written as JSON to the stdin of every hook
+type HookInput struct {
+	Phase string `json:"phase"`
+	// Workdir is the generated project directory
+	Workdir string `json:"workdir"`
+	// OutputDir receives the built apps and packages
+	OutputDir string `json:"outputDir"`
+	// Artifacts lists the built apps and packages, empty before pre-sign
+	Artifacts []string       `json:"artifacts"`
+	Config    *config.Config `json:"config"`
+}
+
+// hookCommands returns the hook commands configured for phase
+func (b *Builder) hookCommands(phase string) []string {
+	hooks := b.config.Hooks
+	if hooks == nil {
+		return nil
+	}
+	switch phase {
+	case HookPreGenerate:
+		return hooks.PreGenerate
+	case HookPostGenerate:
+		return hooks.PostGenerate
+	case HookPreSign:
+		return hooks.PreSign
+	case HookPostBuild:
+		return hooks.PostBuild
+	}
+	return nil
+}
+
+// runHooks runs the hooks of phase one after another as a build step. The
+// first failing hook aborts the build.
+func (b *Builder) runHooks(ctx context.Context, phase string, projectDir string) error {
+	commands := b.hookCommands(phase)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	input, err := b.hookInput(phase, projectDir)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to encode hook input: %w", err)
+	}
+	env := b.childEnv(
+		"PAKE_HOOK="+phase,
+		"PAKE_WORKDIR="+input.Workdir,
+		"PAKE_OUTPUT_DIR="+input.OutputDir,
+		"PAKE_ARTIFACTS="+strings.Join(input.Artifacts, string(os.PathListSeparator)),
+		"PAKE_APP_NAME="+b.config.Name,
+		"PAKE_APP_VERSION="+b.config.AppVersion(),
+	)
+
+	return b.step(ctx, "hook "+phase, func(ctx context.Context) error {
+		for _, line := range commands {
+			cmd := command(ctx, shell[0], append(shell[1:], line)...)
+			cmd.Env = env
+			cmd.Stdin = bytes.NewReader(data)
+			output := &logWriter{b: b}
+			cmd.Stdout = output
+			cmd.Stderr = output
+			err := cmd.Run()
+			output.Close()
+			if err != nil {
+				return fmt.Errorf("hook %q failed: %w", line, err)
+			}
+		}
+		return nil
+	})
+}
+
+// hookInput describes the build to the hooks of phase, with absolute paths
+func (b *Builder) hookInput(phase string, projectDir string) (*HookInput, error) {
+	outputDir, err := filepath.Abs(b.outputDir())
+	if err != nil {
+		return nil, err
+	}
+	input := &HookInput{
+		Phase:     phase,
+		Workdir:   projectDir,
+		OutputDir: outputDir,
+		Artifacts: []string{},
+		Config:    b.config,
+	}
+	if phase == HookPreSign || phase == HookPostBuild {
+		for _, artifact := range b.artifacts {
+			path, err := filepath.Abs(artifact)
+			if err != nil {
+				return nil, err
+			}
+			input.Artifacts = append(input.Artifacts, path)
+		}
+	}
+	return input, nil
+}
+
+// shell runs hook commands
+var shell = func() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C"}
+	}
+	return []string{"sh", "-c"}
+}()
@@ -0,0 +1,85 @@
+//go:build !windows
+
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestRunHooks(t *testing.T) {
+	out := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Name = "TestApp"
+	cfg.Hooks = &config.HooksConfig{
+		PostBuild: []string{
+			`cat > "` + filepath.Join(out, "input.json") + `"`,
+			`echo "$PAKE_HOOK $PAKE_APP_NAME $PAKE_ARTIFACTS"`,
+		},
+	}
+
+	rec := &recorder{}
+	b := NewBuilder(cfg)
+	b.Reporter = rec
+	b.OutputDir = out
+	b.artifacts = []string{filepath.Join(out, "bin", "TestApp")}
+	projectDir := t.TempDir()
+	if err := b.runHooks(context.Background(), HookPostBuild, projectDir); err != nil {
+		t.Fatalf("Failed to run hooks: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "input.json"))
+	if err != nil {
+		t.Fatalf("Failed to read hook input: %v", err)
+	}
+	var input HookInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		t.Fatalf("Invalid hook input: %v", err)
+	}
+	if input.Phase != HookPostBuild || input.Workdir != projectDir || input.Config.Name != "TestApp" || len(input.Artifacts) != 1 {
+		t.Errorf("Unexpected hook input %+v", input)
+	}
+
+	var logged bool
+	for _, event := range rec.events {
+		if event.Type == EventLog && event.Step == "hook post-build" {
+			logged = event.Message == "post-build TestApp "+b.artifacts[0]
+		}
+	}
+	if !logged {
+		t.Errorf("Expected the hook output as a log event, got %v", rec.events)
+	}
+
+	// Phases without hooks do not report a step
+	rec.events = nil
+	if err := b.runHooks(context.Background(), HookPreGenerate, projectDir); err != nil || len(rec.events) != 0 {
+		t.Errorf("Expected no step for a phase without hooks, got %v, %v", err, rec.events)
+	}
+
+	// Hooks see the artifacts before signing but not the secret key
+	t.Setenv("PAKE_SIGNING_KEY", "secret")
+	cfg.Hooks.PreSign = []string{`echo "$PAKE_HOOK ${PAKE_SIGNING_KEY:-no key} $PAKE_ARTIFACTS"`}
+	rec.events = nil
+	if err := b.runHooks(context.Background(), HookPreSign, projectDir); err != nil {
+		t.Fatalf("Failed to run hooks: %v", err)
+	}
+	logged = false
+	for _, event := range rec.events {
+		logged = logged || event.Message == "pre-sign no key "+b.artifacts[0]
+	}
+	if !logged {
+		t.Errorf("Expected the artifacts without the signing key, got %v", rec.events)
+	}
+
+	cfg.Hooks.PreGenerate = []string{"exit 3", "touch never"}
+	err = b.runHooks(context.Background(), HookPreGenerate, projectDir)
+	if err == nil || !strings.Contains(err.Error(), `hook "exit 3" failed`) {
+		t.Errorf("Expected the failing hook to abort, got %v", err)
+	}
+}
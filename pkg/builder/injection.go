@@ -0,0 +1,41 @@
+package builder
+
+const injectionTemplate = `package main
+
+import "sync/atomic"
+
+// activeRules are the injection rules pages are customized with
+var activeRules atomic.Pointer[WebViewManager]
+
+// builtinRules returns the rules the app was built with: injectCSS and
+// injectJS apply to every page, the inject rules to the pages they match
+func builtinRules() *WebViewManager {
+	rules := NewWebViewManager()
+	rules.AddRule("", {{printf "%#v" .InjectCSS}}, {{printf "%#v" .InjectJS}}, nil)
+{{- range .InjectRules}}
+	rules.InsertRule(Rule{
+		Match: &Matcher{Type: {{printf "%q" .EffectiveMatch}}, Pattern: {{printf "%q" .URL}}, Host: {{printf "%q" .Host}}, Path: {{printf "%q" .Path}}, Query: {{printf "%#v" .Query}}},
+		CSS:   {{printf "%#v" .CSS}},
+		JS:    {{printf "%#v" .JS}},
+	})
+{{- end}}
+	return rules
+}
+
+// rulesInjectionScript returns the script applying the active rules to url
+func rulesInjectionScript(url string) string {
+	rules := activeRules.Load()
+	if rules == nil {
+		return ""
+	}
+	css, js, _ := rules.GetRulesForURL(url)
+	return rules.GenerateInjectionScript(css, js)
+}
+
+// rulesScript asks the app for the rules of the current page and applies them
+func rulesScript() string {
+	return "if (window.go && window.go.main && window.go.main.App && window.go.main.App.InjectionScript) {\n" +
+		"\twindow.go.main.App.InjectionScript(window.location.href).then(function(script) { if (script) (0, eval)(script); });\n" +
+		"}"
+}
+`
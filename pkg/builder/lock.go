@@ -0,0 +1,307 @@
+package builder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zk3151463/pake-go/pkg/signing"
+)
+
+// WailsVersion is the Wails release generated apps are built with
+const WailsVersion = "v2.10.1"
+
+// lockVersion is the format version of lock files
+const lockVersion = 1
+
+// lockedFiles are the dependency files of the generated project recorded in
+// the lock file
+var lockedFiles = []string{"go.mod", "go.sum", "frontend/package-lock.json"}
+
+// Toolchain records the versions of the tools that produce the binary
+type Toolchain struct {
+	Go    string `json:"go"`
+	Wails string `json:"wails"`
+	Node  string `json:"node"`
+	NPM   string `json:"npm"`
+}
+
+// detectToolchain returns the versions of the tools found in PATH
+func detectToolchain() Toolchain {
+	return Toolchain{
+		Go:    toolVersion("go", "env", "GOVERSION"),
+		Wails: toolVersion("wails", "version"),
+		Node:  toolVersion("node", "--version"),
+		NPM:   toolVersion("npm", "--version"),
+	}
+}
+
+// toolVersion returns the first line printed by a version command
+func toolVersion(name string, args ...string) string {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "missing"
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line)
+}
+
+// String describes the toolchain on one line per tool
+func (t Toolchain) String() string {
+	return fmt.Sprintf("go %s\nwails %s\nnode %s\nnpm %s", t.Go, t.Wails, t.Node, t.NPM)
+}
+
+// check returns an error listing the tools that differ from locked. Go is
+// not checked, as the locked version is selected with GOTOOLCHAIN.
+func (t Toolchain) check(locked Toolchain) error {
+	var diffs []string
+	if t.Wails != locked.Wails {
+		diffs = append(diffs, fmt.Sprintf("wails is %s, locked %s (go install github.com/wailsapp/wails/v2/cmd/wails@%s)", t.Wails, locked.Wails, locked.Wails))
+	}
+	if t.Node != locked.Node {
+		diffs = append(diffs, fmt.Sprintf("node is %s, locked %s", t.Node, locked.Node))
+	}
+	if t.NPM != locked.NPM {
+		diffs = append(diffs, fmt.Sprintf("npm is %s, locked %s", t.NPM, locked.NPM))
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("toolchain differs from the lock file: %s", strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// Lock pins everything that affects the built artifacts, so that the same
+// config builds the same binaries later
+type Lock struct {
+	Version   int       `json:"version"`
+	Toolchain Toolchain `json:"toolchain"`
+	// SourceDateEpoch is the fixed build timestamp, in seconds since the epoch
+	SourceDateEpoch int64 `json:"sourceDateEpoch"`
+	// ProjectHash is the hash of the generated project before the locked
+	// files are applied; it changes with the config and the templates
+	ProjectHash string `json:"projectHash"`
+	// Files holds the locked go.mod, go.sum and package-lock.json
+	Files map[string]string `json:"files"`
+	// Artifacts maps artifact paths relative to the output dir to SHA-256 hashes
+	Artifacts map[string]string `json:"artifacts"`
+}
+
+// LoadLock reads a lock file
+func LoadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file: %w", err)
+	}
+	if lock.Version != lockVersion {
+		return nil, fmt.Errorf("unsupported lock file version %d", lock.Version)
+	}
+	return &lock, nil
+}
+
+// Save writes the lock file
+func (l *Lock) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Diff lists the artifacts whose hashes differ from the locked ones
+func (l *Lock) Diff(artifacts map[string]string) []string {
+	var diffs []string
+	for path, sum := range l.Artifacts {
+		got, ok := artifacts[path]
+		switch {
+		case !ok:
+			diffs = append(diffs, path+" was not built")
+		case got != sum:
+			diffs = append(diffs, fmt.Sprintf("%s has hash %s, locked %s", path, got, sum))
+		}
+	}
+	for path := range artifacts {
+		if _, ok := l.Artifacts[path]; !ok {
+			diffs = append(diffs, path+" is not in the lock file")
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// sourceDateEpoch returns the build timestamp for a new lock file, taken
+// from SOURCE_DATE_EPOCH when set
+func sourceDateEpoch() int64 {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return epoch
+	}
+	return time.Now().Unix()
+}
+
+// prepareLock detects the toolchain and loads the lock file, or starts a new
+// one when it does not exist or UpdateLock is set
+func (b *Builder) prepareLock() (*Lock, error) {
+	b.toolchain = detectToolchain()
+	b.env = nil
+	b.creatingLock = false
+	if b.LockFile == "" {
+		return nil, nil
+	}
+
+	lock, err := LoadLock(b.LockFile)
+	switch {
+	case b.UpdateLock || errors.Is(err, os.ErrNotExist):
+		lock = &Lock{
+			Version:         lockVersion,
+			Toolchain:       b.toolchain,
+			SourceDateEpoch: sourceDateEpoch(),
+		}
+		b.creatingLock = true
+	case err != nil:
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	default:
+		if err := b.toolchain.check(lock.Toolchain); err != nil {
+			return nil, err
+		}
+		b.toolchain.Go = lock.Toolchain.Go
+		if strings.HasPrefix(lock.Toolchain.Go, "go") {
+			b.env = append(b.env, "GOTOOLCHAIN="+lock.Toolchain.Go)
+		}
+	}
+
+	b.config.BuildTime = time.Unix(lock.SourceDateEpoch, 0).UTC()
+	b.env = append(b.env, fmt.Sprintf("SOURCE_DATE_EPOCH=%d", lock.SourceDateEpoch))
+	return lock, nil
+}
+
+// applyLock checks that the generated project matches the lock file and
+// restores the locked dependency files into it
+func (b *Builder) applyLock(lock *Lock, projectDir string) error {
+	h := sha256.New()
+	if err := hashTree(h, projectDir); err != nil {
+		return fmt.Errorf("failed to hash project: %w", err)
+	}
+	projectHash := hex.EncodeToString(h.Sum(nil))
+
+	if b.creatingLock {
+		lock.ProjectHash = projectHash
+		return nil
+	}
+	if lock.ProjectHash != projectHash {
+		return fmt.Errorf("the config or templates changed since %s was written; update it with -update-lock", b.LockFile)
+	}
+
+	for name, content := range lock.Files {
+		path := filepath.Join(projectDir, filepath.FromSlash(name))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to restore locked %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// recordLock hashes the artifacts of the build and, for a new lock file,
+// records the resolved dependency files and writes it
+func (b *Builder) recordLock(lock *Lock, projectDir string) error {
+	artifacts, err := hashArtifacts(b.artifacts, b.outputDir())
+	if err != nil {
+		return fmt.Errorf("failed to hash artifacts: %w", err)
+	}
+	b.artifactHashes = artifacts
+
+	if !b.creatingLock {
+		return nil
+	}
+
+	lock.Files = map[string]string{}
+	for _, name := range lockedFiles {
+		data, err := os.ReadFile(filepath.Join(projectDir, filepath.FromSlash(name)))
+		if err != nil {
+			b.warnf("%s was not generated and is not locked: %v", name, err)
+			continue
+		}
+		lock.Files[name] = string(data)
+	}
+	lock.Artifacts = artifacts
+
+	if err := lock.Save(b.LockFile); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	b.logf("Wrote %s", b.LockFile)
+	return nil
+}
+
+// hashArtifacts returns the SHA-256 hash of every file in paths, keyed by
+// the slash separated path relative to root
+func hashArtifacts(paths []string, root string) (map[string]string, error) {
+	hashes := map[string]string{}
+	for _, artifact := range paths {
+		err := filepath.Walk(artifact, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			sum, err := signing.FileSHA256(path)
+			if err != nil {
+				return err
+			}
+			hashes[filepath.ToSlash(rel)] = sum
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// Verify rebuilds the application from LockFile, without the build cache and
+// into a temporary output directory, and compares the artifacts with the
+// hashes recorded in the lock file. Post-build hooks are not run.
+func (b *Builder) Verify(ctx context.Context) error {
+	if b.LockFile == "" {
+		return errors.New("no lock file to verify against")
+	}
+	lock, err := LoadLock(b.LockFile)
+	if err != nil {
+		return fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "pake-go-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(outputDir)
+
+	b.OutputDir = outputDir
+	b.NoCache = true
+	b.UpdateLock = false
+	b.verifying = true
+	defer func() { b.verifying = false }()
+	if err := b.BuildContext(ctx); err != nil {
+		return err
+	}
+
+	if diffs := lock.Diff(b.artifactHashes); len(diffs) > 0 {
+		return fmt.Errorf("build is not reproducible: %s", strings.Join(diffs, "; "))
+	}
+	b.logf("All %d artifacts match %s", len(lock.Artifacts), b.LockFile)
+	return nil
+}
@@ -0,0 +1,120 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestLockSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pake.lock")
+	lock := &Lock{
+		Version:         lockVersion,
+		Toolchain:       Toolchain{Go: "go1.22.3", Wails: WailsVersion, Node: "v20.11.0", NPM: "10.2.4"},
+		SourceDateEpoch: 1700000000,
+		Files:           map[string]string{"go.sum": "sum"},
+		Artifacts:       map[string]string{"bin/MyApp": "abc"},
+	}
+	if err := lock.Save(path); err != nil {
+		t.Fatalf("Failed to save lock: %v", err)
+	}
+
+	loaded, err := LoadLock(path)
+	if err != nil {
+		t.Fatalf("Failed to load lock: %v", err)
+	}
+	if loaded.Toolchain != lock.Toolchain || loaded.Files["go.sum"] != "sum" {
+		t.Errorf("Unexpected lock %+v", loaded)
+	}
+
+	os.WriteFile(path, []byte(`{"version": 99}`), 0644)
+	if _, err := LoadLock(path); err == nil {
+		t.Error("Expected error for unsupported lock version")
+	}
+}
+
+func TestLockDiff(t *testing.T) {
+	lock := &Lock{Artifacts: map[string]string{"bin/MyApp": "abc", "packages/myapp.deb": "def"}}
+
+	if diffs := lock.Diff(map[string]string{"bin/MyApp": "abc", "packages/myapp.deb": "def"}); len(diffs) != 0 {
+		t.Errorf("Expected no differences, got %v", diffs)
+	}
+
+	diffs := lock.Diff(map[string]string{"bin/MyApp": "xyz", "bin/extra": "123"})
+	want := []string{
+		"bin/MyApp has hash xyz, locked abc",
+		"bin/extra is not in the lock file",
+		"packages/myapp.deb was not built",
+	}
+	if strings.Join(diffs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %v, got %v", want, diffs)
+	}
+}
+
+func TestToolchainCheck(t *testing.T) {
+	locked := Toolchain{Go: "go1.22.3", Wails: "v2.10.1", Node: "v20.11.0", NPM: "10.2.4"}
+
+	// Go is selected with GOTOOLCHAIN and may differ
+	current := locked
+	current.Go = "go1.23.0"
+	if err := current.check(locked); err != nil {
+		t.Errorf("Expected matching toolchain, got %v", err)
+	}
+
+	current.Node = "v22.1.0"
+	if err := current.check(locked); err == nil || !strings.Contains(err.Error(), "node is v22.1.0, locked v20.11.0") {
+		t.Errorf("Expected node mismatch, got %v", err)
+	}
+}
+
+func TestApplyLock(t *testing.T) {
+	cfg := config.DefaultConfig()
+	b := NewBuilder(cfg)
+	b.LockFile = "pake.lock"
+
+	projectDir := t.TempDir()
+	writeFiles(t, projectDir, map[string]string{"main.go": "package main", "go.mod": "module app"})
+
+	// A new lock records the project hash
+	lock := &Lock{}
+	b.creatingLock = true
+	if err := b.applyLock(lock, projectDir); err != nil {
+		t.Fatal(err)
+	}
+	lock.Files = map[string]string{"go.mod": "module app\n\nrequire x v1.0.0"}
+
+	// An existing lock restores the locked files
+	b.creatingLock = false
+	if err := b.applyLock(lock, projectDir); err != nil {
+		t.Fatalf("Failed to apply lock: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(projectDir, "go.mod")); string(data) != lock.Files["go.mod"] {
+		t.Errorf("Expected locked go.mod, got %q", data)
+	}
+
+	// A changed project is rejected
+	changedDir := t.TempDir()
+	writeFiles(t, changedDir, map[string]string{"main.go": "package main // changed", "go.mod": "module app"})
+	if err := b.applyLock(lock, changedDir); err == nil || !strings.Contains(err.Error(), "-update-lock") {
+		t.Errorf("Expected error for changed project, got %v", err)
+	}
+}
+
+func TestHashArtifacts(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"bin/MyApp.app/Contents/MacOS/MyApp": "binary",
+		"packages/myapp.deb":                 "deb",
+	})
+
+	hashes, err := hashArtifacts([]string{filepath.Join(root, "bin", "MyApp.app"), filepath.Join(root, "packages", "myapp.deb")}, root)
+	if err != nil {
+		t.Fatalf("Failed to hash artifacts: %v", err)
+	}
+	if len(hashes) != 2 || hashes["bin/MyApp.app/Contents/MacOS/MyApp"] == "" || hashes["packages/myapp.deb"] == "" {
+		t.Errorf("Unexpected hashes %v", hashes)
+	}
+}
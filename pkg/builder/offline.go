@@ -0,0 +1,228 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// offlineEnv keeps go from reaching the network. Modules come from a local
+// module cache that is trusted without consulting the checksum database.
+func offlineEnv(ctx context.Context) []string {
+	return []string{"GOPROXY=off", "GOSUMDB=off", "GOFLAGS=" + withModMod(goFlags(ctx))}
+}
+
+// goFlags returns the GOFLAGS of the user, set in the environment or with
+// go env -w
+func goFlags(ctx context.Context) string {
+	cmd := command(ctx, "go", "env", "GOFLAGS")
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	out, err := cmd.Output()
+	if err != nil {
+		return os.Getenv("GOFLAGS")
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// withModMod adds -mod=mod to flags, so modules missing from go.sum are
+// resolved from the module cache, unless flags already select a mode
+func withModMod(flags string) string {
+	for _, flag := range strings.Fields(flags) {
+		if strings.HasPrefix(flag, "-mod=") || strings.HasPrefix(flag, "--mod=") {
+			return flags
+		}
+	}
+	return strings.TrimSpace(flags + " -mod=mod")
+}
+
+// prepareOffline makes sure everything the build needs is available locally
+// and reports every missing artifact at once. The Vue frontend is bundled
+// from node_modules in the build cache; without them a static shell is used.
+func (b *Builder) prepareOffline(ctx context.Context, projectDir string) error {
+	var missing []string
+
+	if _, err := lookPath("wails"); err != nil {
+		missing = append(missing, "wails CLI in PATH (go install github.com/wailsapp/wails/v2/cmd/wails@"+WailsVersion+")")
+	}
+
+	modules, err := b.missingModules(ctx, projectDir)
+	if err != nil {
+		return err
+	}
+	missing = append(missing, modules...)
+
+	frontendDir := filepath.Join(projectDir, "frontend")
+	switch {
+	case b.hasCachedNodeModules(frontendDir):
+		if _, err := lookPath("node"); err != nil {
+			missing = append(missing, "node in PATH to bundle the cached frontend")
+		}
+		if b.hasTemplate(offlineShellFile) {
+			b.warnUnusedShell()
+		}
+	default:
+		b.logf("No cached node_modules, using the static frontend shell")
+		if err := b.writeOfflineShell(projectDir); err != nil {
+			return fmt.Errorf("failed to write frontend shell: %w", err)
+		}
+		if err := copyPublicAssets(frontendDir); err != nil {
+			return fmt.Errorf("failed to copy assets into the frontend shell: %w", err)
+		}
+		b.skipFrontend = true
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing for an offline build:\n  - %s", strings.Join(missing, "\n  - "))
+	}
+	return nil
+}
+
+// missingModules lists the Go modules required by the project that are not
+// in the local module cache
+func (b *Builder) missingModules(ctx context.Context, projectDir string) ([]string, error) {
+	cmd := command(ctx, "go", "mod", "download", "-json")
+	cmd.Dir = projectDir
+	cmd.Env = b.childEnv()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var missing []string
+	decoder := json.NewDecoder(&stdout)
+	for {
+		var module struct {
+			Path    string
+			Version string
+			Error   string
+		}
+		if err := decoder.Decode(&module); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse go mod download output: %w", err)
+		}
+		if module.Error != "" {
+			missing = append(missing, fmt.Sprintf("Go module %s@%s in the module cache", module.Path, module.Version))
+		}
+	}
+
+	// go failed before listing modules, e.g. a locked toolchain is missing
+	if runErr != nil && len(missing) == 0 {
+		reason := strings.TrimSpace(stderr.String())
+		if reason == "" {
+			reason = runErr.Error()
+		}
+		missing = append(missing, "Go toolchain: "+reason)
+	}
+	return missing, nil
+}
+
+// hasCachedNodeModules reports whether the build cache holds node_modules
+// for the frontend
+func (b *Builder) hasCachedNodeModules(frontendDir string) bool {
+	if b.NoCache {
+		return false
+	}
+	cache, err := newBuildCache(b.CacheDir, b.toolchain)
+	if err != nil {
+		return false
+	}
+	cached, err := cache.frontendCache(frontendDir)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(cached, "node_modules"))
+	return err == nil
+}
+
+// writeOfflineShell writes a prebuilt frontend that needs no npm packages
+func (b *Builder) writeOfflineShell(projectDir string) error {
+	return b.writeProjectFile(projectDir, offlineShellFile)
+}
+
+// hasTemplate reports whether TemplatesDir overrides the template of name
+func (b *Builder) hasTemplate(name string) bool {
+	if b.config.TemplatesDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(b.config.TemplatesDir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+// warnUnusedShell warns that the static shell template is ignored by a
+// build that bundles the Vue frontend
+func (b *Builder) warnUnusedShell() {
+	b.warnf("template %s is only used by offline builds without cached node_modules and is ignored", offlineShellFile)
+}
+
+// offlineShellFile is the project file of the static frontend shell
+const offlineShellFile = "frontend/dist/index.html"
+
+// offlineShellTemplate is the loading screen of appVueTemplate as static HTML
+const offlineShellTemplate = `<!DOCTYPE html>
+<html lang="en">
+	<head>
+		<meta charset="UTF-8" />
+		<meta content="width=device-width, initial-scale=1.0" name="viewport" />
+		<title>{{html .Name}}</title>
+		<style>
+			:root {
+				--pake-background: {{.ThemeBackground}};
+				color-scheme: {{if eq .Theme "dark"}}dark{{else if eq .Theme "system"}}light dark{{else}}light{{end}};
+			}
+			{{- if eq .Theme "system"}}
+
+			@media (prefers-color-scheme: dark) {
+				:root {
+					--pake-background: {{.DarkBackground}};
+				}
+			}
+			{{- end}}
+
+			html, body {
+				margin: 0;
+				padding: 0;
+				width: 100%;
+				height: 100%;
+				overflow: hidden;
+				background: var(--pake-background);
+			}
+
+			.loading {
+				position: fixed;
+				inset: 0;
+				display: flex;
+				align-items: center;
+				justify-content: center;
+			}
+
+			.spinner {
+				width: 40px;
+				height: 40px;
+				border: 4px solid #f3f3f3;
+				border-top: 4px solid #3498db;
+				border-radius: 50%;
+				animation: spin 1s linear infinite;
+			}
+
+			@keyframes spin {
+				0% { transform: rotate(0deg); }
+				100% { transform: rotate(360deg); }
+			}
+		</style>
+	</head>
+	<body>
+		<div class="loading">
+			<div class="spinner"></div>
+		</div>
+	</body>
+</html>
+`
@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestMissingModules(t *testing.T) {
+	if _, err := lookPath("go"); err != nil {
+		t.Skip("go not in PATH")
+	}
+
+	b := NewBuilder(config.DefaultConfig())
+	b.env = append([]string{"GOMODCACHE=" + t.TempDir()}, offlineEnv(context.Background())...)
+
+	projectDir := t.TempDir()
+	writeFiles(t, projectDir, map[string]string{
+		"go.mod": "module app\n\ngo 1.21\n\nrequire example.com/missing v1.0.0\n",
+	})
+
+	missing, err := b.missingModules(context.Background(), projectDir)
+	if err != nil {
+		t.Fatalf("Failed to check modules: %v", err)
+	}
+	if len(missing) != 1 || !strings.Contains(missing[0], "example.com/missing@v1.0.0") {
+		t.Errorf("Expected the missing module to be listed, got %v", missing)
+	}
+}
+
+func TestPrepareOfflineShell(t *testing.T) {
+	defer func(orig func(string) (string, error)) { lookPath = orig }(lookPath)
+	lookPath = func(file string) (string, error) {
+		return "", errors.New("not found")
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Name = "TestApp"
+	b := NewBuilder(cfg)
+	b.Reporter = &recorder{}
+	b.CacheDir = t.TempDir()
+	b.env = append([]string{"GOMODCACHE=" + t.TempDir()}, offlineEnv(context.Background())...)
+
+	projectDir := t.TempDir()
+	writeFiles(t, projectDir, map[string]string{
+		"go.mod":                "module app\n\ngo 1.21\n",
+		"frontend/package.json": "{}",
+	})
+
+	err := b.prepareOffline(context.Background(), projectDir)
+	if err == nil || !strings.Contains(err.Error(), "wails CLI in PATH") {
+		t.Errorf("Expected missing wails CLI to be listed, got %v", err)
+	}
+
+	// Without cached node_modules the static shell is used
+	if !b.skipFrontend {
+		t.Error("Expected the frontend build to be skipped")
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "frontend", "dist", "index.html")); err != nil {
+		t.Errorf("Expected the static shell to be written: %v", err)
+	}
+}
+
+func TestWithModMod(t *testing.T) {
+	tests := map[string]string{
+		"":                      "-mod=mod",
+		"-trimpath":             "-trimpath -mod=mod",
+		"-trimpath -mod=vendor": "-trimpath -mod=vendor",
+		"--mod=readonly":        "--mod=readonly",
+	}
+	for flags, want := range tests {
+		if got := withModMod(flags); got != want {
+			t.Errorf("withModMod(%q) = %q, want %q", flags, got, want)
+		}
+	}
+}
+
+func TestWarnUnusedShell(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.TemplatesDir = t.TempDir()
+	writeFiles(t, cfg.TemplatesDir, map[string]string{offlineShellFile: "<html></html>"})
+	rec := &recorder{}
+	b := NewBuilder(cfg)
+	b.Reporter = rec
+
+	if err := b.checkTemplatesDir(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.events) != 1 || !strings.Contains(rec.events[0].Message, "only used by offline builds") {
+		t.Errorf("Expected a warning that the shell is ignored online, got %v", rec.events)
+	}
+
+	rec.events = nil
+	b.Offline = true
+	if err := b.checkTemplatesDir(); err != nil || len(rec.events) != 0 {
+		t.Errorf("Expected no warning for an offline build, got %v %v", err, rec.events)
+	}
+}
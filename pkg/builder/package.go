@@ -0,0 +1,66 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/packager"
+)
+
+// packageLinux builds the configured Linux packages from the binary built
+// for t and the hicolor icons generated in the project directory
+func (b *Builder) packageLinux(projectDir string, binDir string, t target) error {
+	pkg := b.config.Package
+	if pkg == nil || len(pkg.Targets) == 0 {
+		return nil
+	}
+	goos, goarch := t.osArch()
+	if goos != "linux" {
+		b.warnf("skipping Linux packages for %s", goos)
+		return nil
+	}
+
+	// Fall back to the app author as the package maintainer
+	maintainer := pkg.Maintainer
+	if maintainer == "" && b.config.Author != "" {
+		maintainer = b.config.Author
+	}
+
+	info := &packager.Info{
+		Name:        packager.PackageName(b.config.Name),
+		AppName:     b.config.Name,
+		Version:     b.config.AppVersion(),
+		Arch:        goarch,
+		Description: b.config.Description,
+		Maintainer:  maintainer,
+		Homepage:    pkg.Homepage,
+		License:     pkg.License,
+		Categories:  pkg.Categories,
+		Depends:     pkg.Depends,
+		WebKit41:    b.config.WebKit == config.WebKit41,
+		Binary:      filepath.Join(binDir, b.config.Name),
+		IconDir:     filepath.Join(projectDir, "build", "linux", "hicolor"),
+		ModTime:     b.config.Timestamp(),
+	}
+
+	outDir := filepath.Join(b.outputDir(), "packages")
+	for _, target := range pkg.Targets {
+		var path string
+		var err error
+		switch target {
+		case config.PackageDeb:
+			path, err = packager.BuildDeb(info, outDir)
+		case config.PackageRPM:
+			path, err = packager.BuildRPM(info, outDir)
+		case config.PackageTarball:
+			path, err = packager.BuildTarball(info, outDir)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to build %s package: %w", target, err)
+		}
+		b.report(Event{Type: EventArtifact, Artifact: path})
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// target is a platform to build for. The zero target builds for the host
+// without passing -platform to wails.
+type target struct {
+	platform string
+	// env holds the extra environment needed to build on this host
+	env []string
+}
+
+// osArch returns the operating system and architecture of the target
+func (t target) osArch() (string, string) {
+	if t.platform == "" {
+		return runtime.GOOS, runtime.GOARCH
+	}
+	goos, goarch, _ := strings.Cut(t.platform, "/")
+	return goos, goarch
+}
+
+// crossCompilers are the GNU toolchain prefixes used to build the cgo based
+// Linux webview for another architecture
+var crossCompilers = map[string]string{
+	"amd64": "x86_64-linux-gnu",
+	"arm64": "aarch64-linux-gnu",
+	"arm":   "arm-linux-gnueabihf",
+	"386":   "i686-linux-gnu",
+}
+
+// lookPath finds cross compilers, replaced in tests
+var lookPath = exec.LookPath
+
+// resolveTarget returns the target for platform when host can build it, or
+// the reason it cannot
+func resolveTarget(platform string, host string) (target, string) {
+	t := target{platform: platform}
+	goos, goarch := t.osArch()
+	hostOS, hostArch, _ := strings.Cut(host, "/")
+
+	switch goos {
+	case "darwin":
+		if hostOS != "darwin" {
+			return t, "macOS apps can only be built on macOS"
+		}
+	case "windows":
+		// WebView2 needs no cgo, so Windows apps can be built on any host
+	case "linux":
+		if hostOS != "linux" {
+			return t, "Linux apps link against GTK and WebKitGTK and can only be built on Linux"
+		}
+		if goarch != hostArch {
+			prefix := crossCompilers[goarch]
+			cc := prefix + "-gcc"
+			if _, err := lookPath(cc); err != nil {
+				return t, fmt.Sprintf("cross-compiling needs %s in PATH and the %s GTK and WebKitGTK development packages", cc, goarch)
+			}
+			t.env = []string{
+				"CGO_ENABLED=1",
+				"CC=" + cc,
+				"PKG_CONFIG_LIBDIR=/usr/lib/" + prefix + "/pkgconfig:/usr/share/pkgconfig",
+			}
+		}
+	default:
+		return t, fmt.Sprintf("unsupported operating system %s", goos)
+	}
+	return t, ""
+}
+
+// buildMatrix builds every configured platform the host can produce into
+// <output>/bin/<os>-<arch>, skipping the others, and reports a summary
+func (b *Builder) buildMatrix(ctx context.Context, projectDir string) error {
+	host := runtime.GOOS + "/" + runtime.GOARCH
+	var summary, failed []string
+	var errs []error
+	built := 0
+
+	for _, platform := range b.config.Platforms {
+		b.currentTarget = platform
+
+		t, reason := resolveTarget(platform, host)
+		if reason != "" {
+			b.report(Event{Type: EventTargetSkipped, Message: reason})
+			summary = append(summary, fmt.Sprintf("%s: skipped, %s", platform, reason))
+			continue
+		}
+
+		finalAppPath := filepath.Join(b.outputDir(), "bin", strings.ReplaceAll(platform, "/", "-"))
+		if err := b.buildTarget(ctx, projectDir, t, finalAppPath); err != nil {
+			if ctx.Err() != nil {
+				b.currentTarget = ""
+				return err
+			}
+			summary = append(summary, fmt.Sprintf("%s: failed, %v", platform, err))
+			failed = append(failed, platform)
+			errs = append(errs, err)
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s: built in %s", platform, finalAppPath))
+		built++
+	}
+	b.currentTarget = ""
+
+	b.logf("Build summary:")
+	for _, line := range summary {
+		b.logf("  %s", line)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to build %s: %w", strings.Join(failed, ", "), errors.Join(errs...))
+	}
+	if built == 0 {
+		return fmt.Errorf("none of the platforms %s can be built on %s", strings.Join(b.config.Platforms, ", "), host)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveTarget(t *testing.T) {
+	defer func(orig func(string) (string, error)) { lookPath = orig }(lookPath)
+	lookPath = func(file string) (string, error) {
+		if file == "aarch64-linux-gnu-gcc" {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	tests := []struct {
+		platform string
+		host     string
+		skipped  bool
+	}{
+		{"linux/amd64", "linux/amd64", false},
+		{"linux/arm64", "linux/amd64", false},
+		{"linux/arm", "linux/amd64", true},
+		{"linux/amd64", "darwin/arm64", true},
+		{"windows/amd64", "linux/amd64", false},
+		{"darwin/universal", "linux/amd64", true},
+		{"darwin/universal", "darwin/arm64", false},
+	}
+
+	for _, tt := range tests {
+		target, reason := resolveTarget(tt.platform, tt.host)
+		if (reason != "") != tt.skipped {
+			t.Errorf("resolveTarget(%s, %s): expected skipped=%v, got reason %q", tt.platform, tt.host, tt.skipped, reason)
+		}
+		if tt.platform == "linux/arm64" && !strings.Contains(strings.Join(target.env, " "), "CC=aarch64-linux-gnu-gcc") {
+			t.Errorf("Expected cross compiler for linux/arm64, got %v", target.env)
+		}
+	}
+
+	if _, reason := resolveTarget("linux/arm", "linux/amd64"); !strings.Contains(reason, "arm-linux-gnueabihf-gcc") {
+		t.Errorf("Expected reason to name the missing compiler, got %q", reason)
+	}
+}
+
+func TestTargetOSArch(t *testing.T) {
+	goos, goarch := target{platform: "linux/arm64"}.osArch()
+	if goos != "linux" || goarch != "arm64" {
+		t.Errorf("Expected linux/arm64, got %s/%s", goos, goarch)
+	}
+}
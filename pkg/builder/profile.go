@@ -0,0 +1,92 @@
+package builder
+
+const profileTemplate = `package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+)
+
+const (
+	profileDir = {{printf "%q" .ProfileDir}}
+	incognito  = {{.Incognito}}
+	// profileArg passes the temporary profile of an incognito instance to the
+	// instances it opens
+	profileArg = "-pake-profile="
+)
+
+// activeProfile is the data directory in use
+var activeProfile string
+
+// hostEnv is the environment of the app before setupProfile changed it
+var hostEnv = os.Environ()
+
+// hostCommand returns a command run in hostEnv, so the processes the app
+// starts do not use its webview data directories
+func hostCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = hostEnv
+	return cmd
+}
+
+// setupProfile prepares the webview data directory that holds cookies,
+// localStorage and IndexedDB, and returns it with a cleanup function. macOS
+// keeps WKWebView data per bundle identifier, so the directory is only
+// applied on Windows and Linux.
+func setupProfile() (string, func()) {
+	cleanup := func() {}
+
+	var dir string
+	if shared := sharedProfile(); incognito && shared != "" {
+		// The instance that created the profile removes it
+		dir = shared
+	} else if incognito {
+		tempDir, err := os.MkdirTemp("", "pake-profile-*")
+		if err != nil {
+			log.Fatal(err)
+		}
+		dir = tempDir
+		cleanup = func() { os.RemoveAll(tempDir) }
+	} else {
+		dir = profileDir
+		if !filepath.IsAbs(dir) {
+			base, err := os.UserConfigDir()
+			if err != nil {
+				log.Fatal(err)
+			}
+			dir = filepath.Join(base, dir)
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if goruntime.GOOS == "linux" {
+		// WebKitGTK derives its website data and cache directories from these.
+		// Wails has no option for them, so the variables are set for the
+		// whole process, GTK included; hostCommand restores them for the
+		// processes the app starts.
+		os.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+		os.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	}
+
+	activeProfile = dir
+	return dir, cleanup
+}
+
+// sharedProfile returns the existing profile passed on the command line
+func sharedProfile() string {
+	for _, arg := range os.Args[1:] {
+		if dir, ok := strings.CutPrefix(arg, profileArg); ok {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				return dir
+			}
+		}
+	}
+	return ""
+}
+`
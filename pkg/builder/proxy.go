@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"strings"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+// webview2ProxyArgs returns the Chromium switches WebView2 needs for the proxy
+func webview2ProxyArgs(proxy *config.ProxyConfig) string {
+	var args []string
+	switch {
+	case proxy.PAC != "":
+		args = append(args, "--proxy-pac-url="+proxy.PAC)
+	case proxy.URL != "":
+		args = append(args, "--proxy-server="+proxy.URL)
+	default:
+		return ""
+	}
+
+	if len(proxy.Bypass) > 0 {
+		args = append(args, "--proxy-bypass-list="+strings.Join(proxy.Bypass, ";"))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// proxyEnv returns the environment variables WebKitGTK honours for the proxy
+func proxyEnv(proxy *config.ProxyConfig) map[string]string {
+	env := make(map[string]string)
+	if proxy.URL == "" {
+		return env
+	}
+
+	for _, key := range []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"} {
+		env[key] = proxy.URL
+	}
+	if strings.HasPrefix(proxy.URL, "socks") {
+		env["all_proxy"] = proxy.URL
+		env["ALL_PROXY"] = proxy.URL
+	}
+
+	if len(proxy.Bypass) > 0 {
+		hosts := make([]string, 0, len(proxy.Bypass))
+		for _, entry := range proxy.Bypass {
+			// no_proxy uses ".example.com" for wildcard suffixes
+			hosts = append(hosts, strings.TrimPrefix(entry, "*"))
+		}
+		env["no_proxy"] = strings.Join(hosts, ",")
+		env["NO_PROXY"] = env["no_proxy"]
+	}
+
+	return env
+}
+
+const proxyTemplate = `package main
+
+import (
+	"log"
+	"os"
+	goruntime "runtime"
+)
+
+// configureProxy applies the proxy settings before the webview starts
+func configureProxy() {
+	switch goruntime.GOOS {
+	case "windows":
+		// WebView2 reads additional Chromium switches from the environment
+		args := {{printf "%q" (webview2ProxyArgs .Proxy)}}
+		if existing := os.Getenv("WEBVIEW2_ADDITIONAL_BROWSER_ARGUMENTS"); existing != "" {
+			args = existing + " " + args
+		}
+		os.Setenv("WEBVIEW2_ADDITIONAL_BROWSER_ARGUMENTS", args)
+	case "linux":
+		// WebKitGTK resolves proxies through GIO, which honours these variables
+{{- range $key, $value := proxyEnv .Proxy}}
+		os.Setenv({{printf "%q" $key}}, {{printf "%q" $value}})
+{{- end}}
+{{- if and .Proxy.PAC (not .Proxy.URL)}}
+		log.Println("PAC files are not supported by WebKitGTK; using system proxy settings")
+{{- end}}
+	default:
+		// WKWebView always follows the system proxy settings
+		log.Println("per-app proxy settings are not supported on this platform; using system proxy settings")
+	}
+}
+`
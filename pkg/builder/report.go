@@ -0,0 +1,190 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType identifies a build progress event
+type EventType string
+
+// Build progress event types
+const (
+	EventStepStarted  EventType = "step_started"
+	EventStepFinished EventType = "step_finished"
+	EventStepFailed   EventType = "step_failed"
+	EventArtifact     EventType = "artifact"
+	EventWarning      EventType = "warning"
+	EventLog          EventType = "log"
+	// EventTargetSkipped reports a platform the host cannot build for
+	EventTargetSkipped EventType = "target_skipped"
+)
+
+// Event is a build progress event
+type Event struct {
+	Type     EventType     `json:"type"`
+	Time     time.Time     `json:"time"`
+	Step     string        `json:"step,omitempty"`
+	Target   string        `json:"target,omitempty"`
+	Duration time.Duration `json:"-"`
+	Artifact string        `json:"artifact,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the duration in milliseconds
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	return json.Marshal(struct {
+		event
+		DurationMS int64 `json:"durationMs,omitempty"`
+	}{event(e), e.Duration.Milliseconds()})
+}
+
+// Reporter receives build progress events
+type Reporter interface {
+	Report(event Event)
+}
+
+// TextReporter renders events as human readable lines
+type TextReporter struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+// NewTextReporter creates a TextReporter writing to w
+func NewTextReporter(w io.Writer) *TextReporter {
+	return &TextReporter{W: w}
+}
+
+// Report writes event as a line of text
+func (r *TextReporter) Report(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	step := event.Step
+	if event.Target != "" {
+		step += " " + event.Target
+	}
+
+	switch event.Type {
+	case EventStepStarted:
+		fmt.Fprintf(r.W, "==> %s\n", step)
+	case EventStepFinished:
+		fmt.Fprintf(r.W, "✓ %s (%s)\n", step, event.Duration.Round(time.Millisecond))
+	case EventStepFailed:
+		fmt.Fprintf(r.W, "✗ %s (%s): %s\n", step, event.Duration.Round(time.Millisecond), event.Error)
+	case EventTargetSkipped:
+		fmt.Fprintf(r.W, "- skipping %s: %s\n", event.Target, event.Message)
+	case EventArtifact:
+		fmt.Fprintf(r.W, "Created %s\n", event.Artifact)
+	case EventWarning:
+		fmt.Fprintf(r.W, "Warning: %s\n", event.Message)
+	default:
+		fmt.Fprintf(r.W, "    %s\n", event.Message)
+	}
+}
+
+// JSONReporter writes every event as a line of JSON
+type JSONReporter struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+// NewJSONReporter creates a JSONReporter writing to w
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{W: w}
+}
+
+// Report writes event as a line of JSON
+func (r *JSONReporter) Report(event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.W.Write(append(data, '\n'))
+}
+
+// report sends event to the reporter of the builder
+func (b *Builder) report(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Step == "" {
+		event.Step = b.currentStep
+	}
+	if event.Target == "" {
+		event.Target = b.currentTarget
+	}
+	if event.Type == EventArtifact {
+		b.artifacts = append(b.artifacts, event.Artifact)
+		if b.artifactTargets == nil {
+			b.artifactTargets = map[string]string{}
+		}
+		b.artifactTargets[event.Artifact] = event.Target
+	}
+	b.Reporter.Report(event)
+}
+
+// warnf reports a warning for the current step
+func (b *Builder) warnf(format string, args ...interface{}) {
+	b.report(Event{Type: EventWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// logf reports a message for the current step
+func (b *Builder) logf(format string, args ...interface{}) {
+	b.report(Event{Type: EventLog, Message: fmt.Sprintf(format, args...)})
+}
+
+// logWriter reports every line written to it as a log event, so that the
+// output of child processes does not break structured logs
+type logWriter struct {
+	mu  sync.Mutex
+	b   *Builder
+	buf bytes.Buffer
+}
+
+// Write reports the complete lines in p and buffers the rest
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Keep the partial line for the next write
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			return len(p), nil
+		}
+		w.emit(line)
+	}
+}
+
+// Close reports a trailing partial line
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() > 0 {
+		w.emit(w.buf.String())
+		w.buf.Reset()
+	}
+	return nil
+}
+
+// emit reports one line of output, skipping blank lines
+func (w *logWriter) emit(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) != "" {
+		w.b.logf("%s", line)
+	}
+}
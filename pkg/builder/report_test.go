@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+// recorder collects reported events
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Report(event Event) {
+	r.events = append(r.events, event)
+}
+
+func TestStepEvents(t *testing.T) {
+	rec := &recorder{}
+	b := NewBuilder(config.DefaultConfig())
+	b.Reporter = rec
+
+	if err := b.step(context.Background(), "generate", func(ctx context.Context) error {
+		b.warnf("icon is %dx%d", 16, 16)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	b.step(context.Background(), "wails build", func(ctx context.Context) error {
+		return errors.New("exit status 1")
+	})
+
+	var got []string
+	for _, event := range rec.events {
+		got = append(got, fmt.Sprintf("%s %s", event.Type, event.Step))
+	}
+	want := []string{
+		"step_started generate",
+		"warning generate",
+		"step_finished generate",
+		"step_started wails build",
+		"step_failed wails build",
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+	if last := rec.events[len(rec.events)-1]; last.Error != "exit status 1" {
+		t.Errorf("Expected step error, got %q", last.Error)
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	var out strings.Builder
+	r := NewJSONReporter(&out)
+	r.Report(Event{Type: EventStepFinished, Step: "wails build", Duration: 1500 * time.Millisecond})
+	r.Report(Event{Type: EventArtifact, Artifact: "build/bin/MyApp"})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per event, got %q", out.String())
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("Invalid JSON event: %v", err)
+	}
+	if event["type"] != "step_finished" || event["step"] != "wails build" || event["durationMs"] != float64(1500) {
+		t.Errorf("Unexpected event %v", event)
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	rec := &recorder{}
+	b := NewBuilder(config.DefaultConfig())
+	b.Reporter = rec
+	b.currentStep = "wails build"
+
+	w := &logWriter{b: b}
+	fmt.Fprint(w, "• Generating bindings: ")
+	fmt.Fprint(w, "Done.\r\n\n• Compiling")
+	w.Close()
+
+	if len(rec.events) != 2 {
+		t.Fatalf("Expected two log lines, got %+v", rec.events)
+	}
+	if rec.events[0].Message != "• Generating bindings: Done." || rec.events[1].Message != "• Compiling" {
+		t.Errorf("Unexpected log lines %+v", rec.events)
+	}
+	if rec.events[0].Step != "wails build" {
+		t.Errorf("Expected log line to carry the step, got %q", rec.events[0].Step)
+	}
+}
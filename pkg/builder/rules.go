@@ -0,0 +1,182 @@
+package builder
+
+const rulesTemplate = `package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	rulesURL       = {{printf "%q" .Rules.URL}}
+	rulesPublicKey = {{printf "%q" (publicKeyLine .Rules.PublicKey)}}
+	rulesInterval  = {{printf "%q" .Rules.EffectiveInterval}}
+)
+
+// rulesBundle is the signed rules bundle served at rulesURL
+type rulesBundle struct {
+	// Version increases with every published bundle; older bundles are
+	// rejected so a signed bundle cannot be rolled back
+	Version int64 ` + "`" + `json:"version"` + "`" + `
+	Rules   []struct {
+		ID          string ` + "`" + `json:"id"` + "`" + `
+		Description string ` + "`" + `json:"description"` + "`" + `
+		Priority    int    ` + "`" + `json:"priority"` + "`" + `
+		// Enabled is true when omitted
+		Enabled *bool ` + "`" + `json:"enabled"` + "`" + `
+		// Match is the match type of URL, legacy when empty
+		Match MatchType ` + "`" + `json:"match"` + "`" + `
+		URL   string    ` + "`" + `json:"url"` + "`" + `
+		// Host, Path and Query are matched by the url match type
+		Host    string            ` + "`" + `json:"host"` + "`" + `
+		Path    string            ` + "`" + `json:"path"` + "`" + `
+		Query   map[string]string ` + "`" + `json:"query"` + "`" + `
+		CSS     []string          ` + "`" + `json:"css"` + "`" + `
+		JS      []string          ` + "`" + `json:"js"` + "`" + `
+		Headers map[string]string ` + "`" + `json:"headers"` + "`" + `
+	} ` + "`" + `json:"rules"` + "`" + `
+}
+
+// rulesVersion is the version of the bundle of activeRules, 0 for the
+// built-in rules
+var rulesVersion atomic.Int64
+
+// startRules uses the cached rules bundle, or the built-in rules without a
+// valid one, and fetches the bundle in the background at startup and then
+// every rulesInterval
+func startRules() {
+	activeRules.Store(builtinRules())
+
+	cache, err := rulesCachePath()
+	if err != nil {
+		log.Printf("rules cache disabled: %v", err)
+	} else if err := loadCachedRules(cache); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("ignoring cached rules: %v", err)
+	}
+
+	interval, _ := time.ParseDuration(rulesInterval)
+	go func() {
+		for {
+			if err := fetchRules(cache); err != nil {
+				log.Printf("rules update failed: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// rulesCachePath returns where the last valid bundle is kept
+func rulesCachePath() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "pake-go", {{printf "%q" (packageName .Name)}}, "rules.json"), nil
+}
+
+// loadCachedRules verifies the cached bundle and uses it
+func loadCachedRules(cache string) error {
+	data, err := os.ReadFile(cache)
+	if err != nil {
+		return err
+	}
+	signature, err := os.ReadFile(cache + ".sig")
+	if err != nil {
+		return err
+	}
+	rules, version, err := parseRules(data, signature)
+	if err != nil {
+		return err
+	}
+	activeRules.Store(rules)
+	rulesVersion.Store(version)
+	return nil
+}
+
+// fetchRules downloads the bundle and, when it is valid, uses it and keeps it
+// in cache. The current rules stay in use otherwise.
+func fetchRules(cache string) error {
+	data, err := download(rulesURL)
+	if err != nil {
+		return err
+	}
+	signature, err := download(rulesURL + ".sig")
+	if err != nil {
+		return err
+	}
+	rules, version, err := parseRules(data, signature)
+	if err != nil {
+		return err
+	}
+	if current := rulesVersion.Load(); version < current {
+		return fmt.Errorf("rules version %d is older than version %d in use", version, current)
+	}
+	activeRules.Store(rules)
+	rulesVersion.Store(version)
+
+	if cache == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cache), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(cache, data, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(cache+".sig", signature, 0600)
+}
+
+// parseRules verifies a bundle and returns its rules and version
+func parseRules(data []byte, signature []byte) (*WebViewManager, int64, error) {
+	if _, err := verifySignature(rulesPublicKey, data, string(signature)); err != nil {
+		return nil, 0, err
+	}
+	var bundle rulesBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, 0, fmt.Errorf("invalid rules: %w", err)
+	}
+	if bundle.Version < 1 {
+		return nil, 0, errors.New("rules have no version")
+	}
+
+	rules := NewWebViewManager()
+	for i, rule := range bundle.Rules {
+		var match *Matcher
+		var err error
+		switch rule.Match {
+		case "":
+			match, err = NewMatcher(MatchLegacy, rule.URL)
+		case MatchURL:
+			match, err = NewURLMatcher(rule.Host, rule.Path, rule.Query)
+		default:
+			match, err = NewMatcher(rule.Match, rule.URL)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid rule %d: %w", i+1, err)
+		}
+		// Pages are loaded by the webview, which cannot set request
+		// headers, so the rest of the rule still applies without them
+		if len(rule.Headers) > 0 {
+			log.Printf("ignoring the headers of rule %d: the webview cannot set request headers", i+1)
+		}
+		if _, err := rules.InsertRule(Rule{
+			ID:          rule.ID,
+			Description: rule.Description,
+			Priority:    rule.Priority,
+			Disabled:    rule.Enabled != nil && !*rule.Enabled,
+			Match:       match,
+			CSS:         rule.CSS,
+			JS:          rule.JS,
+		}); err != nil {
+			return nil, 0, fmt.Errorf("invalid rule %d: %w", i+1, err)
+		}
+	}
+	return rules, bundle.Version, nil
+}
+`
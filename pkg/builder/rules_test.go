@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/signing"
+)
+
+// rulesHarness fetches the rules bundle once into the cache named on the
+// command line and prints the script for a page
+const rulesHarness = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	activeRules.Store(builtinRules())
+	loadCachedRules(os.Args[1])
+	if err := fetchRules(os.Args[1]); err != nil {
+		fmt.Println("error:", err)
+	}
+	fmt.Print("script:", rulesInjectionScript("https://example.com/app"))
+}
+`
+
+func TestRulesEndToEnd(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not in PATH")
+	}
+
+	key, err := signing.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDir := t.TempDir()
+	writeFiles(t, keyDir, map[string]string{"pake.pub": string(key.Public().Encode())})
+
+	bundleDir := t.TempDir()
+	publish := func(bundle string, signer *signing.PrivateKey) {
+		writeFiles(t, bundleDir, map[string]string{
+			"rules.json":     bundle,
+			"rules.json.sig": string(signing.Sign(signer, []byte(bundle), "file:rules.json")),
+		})
+	}
+	other, err := signing.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(bundleDir)))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.InjectCSS = []string{"builtin{}"}
+	cfg.InjectRules = []config.InjectRule{
+		{Match: "glob", URL: "*.example.com/app", CSS: []string{"glob{}"}},
+		{Match: "url", Host: "example.com", Path: "/other", CSS: []string{"other{}"}},
+	}
+	cfg.Rules = &config.RulesConfig{
+		URL:       server.URL + "/rules.json",
+		PublicKey: filepath.Join(keyDir, "pake.pub"),
+	}
+	projectDir := t.TempDir()
+	for _, name := range []string{"webview.go", "match.go", "injection.go", "rules.go", "download.go"} {
+		if err := NewBuilder(cfg).writeProjectFile(projectDir, name); err != nil {
+			t.Fatalf("Failed to generate %s: %v", name, err)
+		}
+	}
+	writeFiles(t, projectDir, map[string]string{
+		"go.mod":  "module app\n\ngo 1.21\n",
+		"main.go": rulesHarness,
+	})
+	harness := filepath.Join(t.TempDir(), "harness")
+	build := exec.Command("go", "build", "-o", harness, ".")
+	build.Dir = projectDir
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build the rules harness: %v\n%s", err, out)
+	}
+	cache := filepath.Join(t.TempDir(), "rules.json")
+	run := func() string {
+		out, _ := exec.Command(harness, cache).CombinedOutput()
+		return string(out)
+	}
+
+	// Without a bundle the built-in rules apply
+	if out := run(); !strings.Contains(out, "error:") || !strings.Contains(out, "builtin{}") ||
+		!strings.Contains(out, "glob{}") || strings.Contains(out, "other{}") {
+		t.Errorf("Expected the built-in rules without a bundle, got %s", out)
+	}
+
+	publish(`{"version": 2, "rules": [
+		{"id": "late", "priority": 10, "url": "example.com", "css": ["late{}"]},
+		{"url": "example.com", "css": ["remote{}"]},
+		{"url": "example.com", "css": ["disabled{}"], "enabled": false},
+		{"match": "glob", "url": "*.example.com/app", "js": ["app()"]},
+		{"match": "url", "host": "example.com", "path": "/other", "js": ["other()"]}
+	]}`, key)
+	if out := run(); strings.Contains(out, "error:") || !strings.Contains(out, "remote{}") || !strings.Contains(out, "app()") ||
+		strings.Contains(out, "other()") || strings.Contains(out, "disabled{}") || strings.Index(out, "late{}") < strings.Index(out, "remote{}") {
+		t.Errorf("Expected the rules of the bundle, got %s", out)
+	}
+
+	// Invalid bundles keep the cached rules
+	invalid := []struct {
+		bundle string
+		signer *signing.PrivateKey
+		err    string
+	}{
+		{`{"version": 3, "rules": [{"match": "regex", "url": "(", "css": ["broken{}"]}]}`, key, "invalid rule 1"},
+		{`{"rules": [{"url": "example.com", "css": ["old{}"]}]}`, key, "no version"},
+		{`{"version": 1, "rules": [{"url": "example.com", "css": ["old{}"]}]}`, key, "older than version 2"},
+		{`{"version": 3, "rules": [{"url": "example.com", "css": ["evil{}"]}]}`, other, "different key"},
+	}
+	for _, tt := range invalid {
+		publish(tt.bundle, tt.signer)
+		if out := run(); !strings.Contains(out, tt.err) || !strings.Contains(out, "remote{}") {
+			t.Errorf("Expected the cached rules after a bundle failing with %q, got %s", tt.err, out)
+		}
+	}
+
+	// Headers are ignored, the rest of their rule applies
+	publish(`{"version": 3, "rules": [{"url": "example.com", "css": ["headed{}"], "headers": {"X-A": "b"}}]}`, key)
+	if out := run(); strings.Contains(out, "error:") || !strings.Contains(out, "ignoring the headers of rule 1") || !strings.Contains(out, "headed{}") {
+		t.Errorf("Expected the rule without its headers, got %s", out)
+	}
+}
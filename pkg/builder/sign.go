@@ -0,0 +1,121 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/zk3151463/pake-go/pkg/signing"
+)
+
+// signArtifacts runs the platform signers on the artifacts of the build,
+// then writes SHA256SUMS and, with a key, its signature to the output dir
+func (b *Builder) signArtifacts(ctx context.Context) error {
+	for _, artifact := range b.artifacts {
+		if err := b.runSigner(ctx, artifact); err != nil {
+			return err
+		}
+	}
+
+	outputDir := b.outputDir()
+	hashes, err := hashArtifacts(b.artifacts, outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to hash artifacts: %w", err)
+	}
+	sums := signing.FormatChecksums(hashes)
+	sumsPath := filepath.Join(outputDir, signing.ChecksumsFile)
+	if err := os.WriteFile(sumsPath, sums, 0644); err != nil {
+		return fmt.Errorf("failed to write checksums: %w", err)
+	}
+	b.report(Event{Type: EventArtifact, Artifact: sumsPath})
+
+	key, err := b.signingKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		b.warnf("no signing key configured, %s is not signed", signing.ChecksumsFile)
+		return nil
+	}
+
+	comment := fmt.Sprintf("timestamp:%d\tfile:%s\tapp:%s %s",
+		b.config.Timestamp().Unix(), signing.ChecksumsFile, b.config.Name, b.config.AppVersion())
+	sigPath := filepath.Join(outputDir, signing.SignatureFile)
+	if err := os.WriteFile(sigPath, signing.Sign(key, sums, comment), 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	b.report(Event{Type: EventArtifact, Artifact: sigPath})
+	return nil
+}
+
+// signingKeyEnv holds the secret key, e.g. in CI
+const signingKeyEnv = "PAKE_SIGNING_KEY"
+
+// childEnv returns the environment of the commands run by the build: the
+// environment of pake-go, without the secret key only pake-go reads, and the
+// build environment
+func (b *Builder) childEnv(extra ...string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); !strings.EqualFold(name, signingKeyEnv) {
+			env = append(env, kv)
+		}
+	}
+	return append(append(env, b.env...), extra...)
+}
+
+// signingKey loads the configured secret key, or the one in PAKE_SIGNING_KEY
+func (b *Builder) signingKey() (*signing.PrivateKey, error) {
+	if path := b.config.Signing.Key; path != "" {
+		key, err := signing.LoadPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signing key: %w", err)
+		}
+		return key, nil
+	}
+	if data := os.Getenv(signingKeyEnv); data != "" {
+		key, err := signing.ParsePrivateKey([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signing key from PAKE_SIGNING_KEY: %w", err)
+		}
+		return key, nil
+	}
+	return nil, nil
+}
+
+// runSigner runs the signer configured for the OS an artifact was built for.
+// Signers whose tool is not installed are skipped with a warning.
+func (b *Builder) runSigner(ctx context.Context, artifact string) error {
+	goos := runtime.GOOS
+	if target := b.artifactTargets[artifact]; target != "" {
+		goos, _, _ = strings.Cut(target, "/")
+	}
+	line := b.config.Signing.Signers[goos]
+	if line == "" {
+		return nil
+	}
+
+	fields := strings.Fields(line)
+	if _, err := lookPath(fields[0]); err != nil {
+		b.warnf("%s is not installed, %s is not signed", fields[0], artifact)
+		return nil
+	}
+
+	path, err := filepath.Abs(artifact)
+	if err != nil {
+		return err
+	}
+	cmd := command(ctx, shell[0], append(shell[1:], line)...)
+	cmd.Env = b.childEnv("PAKE_ARTIFACT=" + path)
+	output := &logWriter{b: b}
+	defer output.Close()
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to sign %s: %w", artifact, err)
+	}
+	return nil
+}
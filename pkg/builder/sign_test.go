@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/signing"
+)
+
+func TestSignArtifacts(t *testing.T) {
+	key, err := signing.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "pake.key")
+	if err := os.WriteFile(keyPath, key.Encode(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(orig func(string) (string, error)) { lookPath = orig }(lookPath)
+	lookPath = func(file string) (string, error) {
+		return "", os.ErrNotExist
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Name = "TestApp"
+	cfg.Signing = &config.SigningConfig{
+		Key:     keyPath,
+		Signers: map[string]string{"windows": "signtool sign \"$PAKE_ARTIFACT\""},
+	}
+	rec := &recorder{}
+	b := NewBuilder(cfg)
+	b.Reporter = rec
+	b.OutputDir = t.TempDir()
+	writeFiles(t, b.OutputDir, map[string]string{
+		"bin/TestApp":         "linux",
+		"windows-amd64/a.exe": "windows",
+	})
+	b.report(Event{Type: EventArtifact, Artifact: filepath.Join(b.OutputDir, "bin", "TestApp")})
+	b.report(Event{Type: EventArtifact, Target: "windows/amd64", Artifact: filepath.Join(b.OutputDir, "windows-amd64", "a.exe")})
+
+	if err := b.signArtifacts(context.Background()); err != nil {
+		t.Fatalf("Failed to sign artifacts: %v", err)
+	}
+
+	sums, err := os.ReadFile(filepath.Join(b.OutputDir, signing.ChecksumsFile))
+	if err != nil {
+		t.Fatalf("Failed to read checksums: %v", err)
+	}
+	sig, err := os.ReadFile(filepath.Join(b.OutputDir, signing.SignatureFile))
+	if err != nil {
+		t.Fatalf("Failed to read signature: %v", err)
+	}
+	if _, err := signing.Verify(key.Public(), sums, sig); err != nil {
+		t.Errorf("Expected a valid signature: %v", err)
+	}
+
+	hashes, err := signing.ParseChecksums(sums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 || signing.CheckFiles(b.OutputDir, hashes) != nil {
+		t.Errorf("Expected checksums of both artifacts, got %v", hashes)
+	}
+
+	// The missing windows signer is skipped with a warning
+	var warned bool
+	for _, event := range rec.events {
+		warned = warned || event.Type == EventWarning
+	}
+	if !warned {
+		t.Errorf("Expected a warning about the missing signtool")
+	}
+}
@@ -0,0 +1,175 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/packager"
+)
+
+// templateFuncs are the helper functions available to generated templates
+var templateFuncs = template.FuncMap{
+	"webview2ProxyArgs": webview2ProxyArgs,
+	"proxyEnv":          proxyEnv,
+	"rgbaLiteral":       rgbaLiteral,
+	"json":              jsonString,
+	"packageName":       packager.PackageName,
+	"wailsVersion":      func() string { return WailsVersion },
+	"publicKeyLine":     publicKeyLine,
+}
+
+// jsonString encodes v as a JSON value for JSON templates
+func jsonString(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// projectTemplates returns the built-in template of every file generated for
+// cfg, keyed by the slash separated path in the project
+func projectTemplates(cfg *config.Config) map[string]string {
+	templates := map[string]string{
+		"main.go":                 mainTemplate,
+		"webview.go":              webviewManagerTemplate,
+		"match.go":                matchTemplate,
+		"theme.go":                themeTemplate,
+		"profile.go":              profileTemplate,
+		"go.mod":                  goModTemplate,
+		"wails.json":              wailsConfigTemplate,
+		"build/darwin/Info.plist": infoPlistTemplate,
+		"frontend/package.json":   packageJSONTemplate,
+		"frontend/vite.config.js": viteConfigTemplate,
+		"frontend/src/App.vue":    appVueTemplate,
+		"frontend/src/main.js":    mainJSTemplate,
+		"frontend/index.html":     indexHTMLTemplate,
+		offlineShellFile:          offlineShellTemplate,
+	}
+
+	if cfg.WindowMode == config.WindowModeWindows {
+		templates["windows.go"] = windowsModeTemplate
+	}
+	if cfg.Badge != nil {
+		templates["badge.go"] = badgeTemplate
+		templates["badge_darwin.go"] = badgeDarwinTemplate
+		templates["badge_other.go"] = badgeOtherTemplate
+	}
+	if cfg.Update != nil {
+		templates["update.go"] = updateTemplate
+	}
+	if cfg.Injects() {
+		templates["injection.go"] = injectionTemplate
+	}
+	if cfg.Rules != nil {
+		templates["rules.go"] = rulesTemplate
+	}
+	if cfg.Update != nil || cfg.Rules != nil {
+		templates["download.go"] = downloadTemplate
+	}
+	if len(cfg.Assets) > 0 {
+		templates["assets.go"] = assetsTemplate
+	}
+	if cfg.Proxy != nil && cfg.Proxy.Enabled() {
+		templates["proxy.go"] = proxyTemplate
+	}
+
+	return templates
+}
+
+// writeProjectFile renders the project file name with the builder config.
+// A file of the same name in TemplatesDir replaces the built-in template;
+// files not generated for the config are skipped.
+func (b *Builder) writeProjectFile(projectDir string, name string) error {
+	text, ok := projectTemplates(b.config)[name]
+	if !ok {
+		return nil
+	}
+
+	if b.config.TemplatesDir != "" {
+		data, err := os.ReadFile(filepath.Join(b.config.TemplatesDir, filepath.FromSlash(name)))
+		switch {
+		case err == nil:
+			text = string(data)
+		case !os.IsNotExist(err):
+			return fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	path := filepath.Join(projectDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, b.config); err != nil {
+		return fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return nil
+}
+
+// checkTemplatesDir warns about files in TemplatesDir that do not override
+// any file generated for the config, which usually means a typo
+func (b *Builder) checkTemplatesDir() error {
+	if b.config.TemplatesDir == "" {
+		return nil
+	}
+
+	templates := projectTemplates(b.config)
+	return filepath.Walk(b.config.TemplatesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(b.config.TemplatesDir, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := templates[filepath.ToSlash(rel)]; !ok {
+			b.warnf("template %s does not match any generated file and is ignored", filepath.ToSlash(rel))
+		} else if filepath.ToSlash(rel) == offlineShellFile && !b.Offline {
+			b.warnUnusedShell()
+		}
+		return nil
+	})
+}
+
+// ExportTemplates writes the built-in templates of every file generated for
+// cfg into dir, as a starting point for TemplatesDir. Existing files are not
+// overwritten. It returns the written paths relative to dir.
+func ExportTemplates(cfg *config.Config, dir string) ([]string, error) {
+	templates := projectTemplates(cfg)
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Lstat(path); err == nil {
+			return nil, fmt.Errorf("%s already exists", path)
+		}
+	}
+
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(templates[name]), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write template %s: %w", name, err)
+		}
+	}
+	return names, nil
+}
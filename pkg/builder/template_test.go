@@ -0,0 +1,96 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestTemplatesDirOverride(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.URL = "https://test.com"
+	cfg.Name = "TestApp"
+	cfg.TemplatesDir = t.TempDir()
+	writeFiles(t, cfg.TemplatesDir, map[string]string{
+		"frontend/index.html": `<title>{{.Name}} by {{.CompanyName}}</title>{{json .URL}}`,
+		"badge.go":            "package main",
+	})
+
+	rec := &recorder{}
+	b := NewBuilder(cfg)
+	b.Reporter = rec
+	projectDir := t.TempDir()
+	if err := b.generateProject(projectDir); err != nil {
+		t.Fatalf("Failed to generate project: %v", err)
+	}
+
+	indexHTML, err := os.ReadFile(filepath.Join(projectDir, "frontend", "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read index.html: %v", err)
+	}
+	if want := `<title>TestApp by TestApp</title>"https://test.com"`; string(indexHTML) != want {
+		t.Errorf("Expected overridden index.html %q, got %q", want, indexHTML)
+	}
+
+	// Templates of files that are not generated are reported, not rendered
+	if _, err := os.Stat(filepath.Join(projectDir, "badge.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected no badge.go without badge config")
+	}
+	var warned bool
+	for _, event := range rec.events {
+		warned = warned || (event.Type == EventWarning && strings.Contains(event.Message, "badge.go"))
+	}
+	if !warned {
+		t.Errorf("Expected a warning about the unused badge.go template")
+	}
+
+	writeFiles(t, cfg.TemplatesDir, map[string]string{"main.go": "{{.Name"})
+	if err := b.generateProject(t.TempDir()); err == nil || !strings.Contains(err.Error(), "main.go") {
+		t.Errorf("Expected a parse error naming main.go, got %v", err)
+	}
+}
+
+func TestExportTemplates(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WindowMode = config.WindowModeWindows
+
+	dir := t.TempDir()
+	names, err := ExportTemplates(cfg, dir)
+	if err != nil {
+		t.Fatalf("Failed to export templates: %v", err)
+	}
+	if len(names) != len(projectTemplates(cfg)) {
+		t.Errorf("Expected every template to be exported, got %v", names)
+	}
+
+	windowsGo, err := os.ReadFile(filepath.Join(dir, "windows.go"))
+	if err != nil || string(windowsGo) != windowsModeTemplate {
+		t.Errorf("Expected the windows.go template, got %v", err)
+	}
+
+	if _, err := ExportTemplates(cfg, dir); err == nil {
+		t.Errorf("Expected export to refuse overwriting templates")
+	}
+}
+
+func TestWebViewTemplatesMatchPackage(t *testing.T) {
+	projectDir := t.TempDir()
+	b := NewBuilder(config.DefaultConfig())
+	for _, name := range []string{"webview.go", "match.go"} {
+		if err := b.writeProjectFile(projectDir, name); err != nil {
+			t.Fatal(err)
+		}
+		generated, _ := os.ReadFile(filepath.Join(projectDir, name))
+		source, err := os.ReadFile(filepath.Join("..", "..", "webview", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Replace(string(source), "package webview\n", "package main\n", 1)
+		if string(generated) != want {
+			t.Errorf("Generated %s differs from the webview package", name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rgbaLiteral converts a #rgb or #rrggbb colour into a Wails options.RGBA literal
+func rgbaLiteral(color string) string {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		value = 0xffffff
+	}
+
+	return fmt.Sprintf("&options.RGBA{R: %d, G: %d, B: %d, A: 255}", value>>16&0xff, value>>8&0xff, value&0xff)
+}
+
+const themeTemplate = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/options"
+)
+
+const (
+	appTheme        = {{printf "%q" .Theme}}
+	lightBackground = {{printf "%q" .LightBackground}}
+	darkBackground  = {{printf "%q" .DarkBackground}}
+	darkFilter      = {{.DarkFilter}}
+)
+
+// systemPrefersDark reports whether the desktop is using a dark theme
+func systemPrefersDark() bool {
+	switch goruntime.GOOS {
+	case "darwin":
+		out, err := hostCommand("defaults", "read", "-g", "AppleInterfaceStyle").Output()
+		return err == nil && strings.Contains(string(out), "Dark")
+	case "linux":
+		out, err := hostCommand("gsettings", "get", "org.gnome.desktop.interface", "color-scheme").Output()
+		if err == nil && strings.Contains(string(out), "dark") {
+			return true
+		}
+		out, err = hostCommand("gsettings", "get", "org.gnome.desktop.interface", "gtk-theme").Output()
+		return err == nil && strings.Contains(strings.ToLower(string(out)), "dark")
+	}
+	// WebView2 follows the Windows theme through options.Windows.Theme
+	return false
+}
+
+// isDark reports whether the app starts in dark mode
+func isDark() bool {
+	switch appTheme {
+	case "dark":
+		return true
+	case "system":
+		return systemPrefersDark()
+	}
+	return false
+}
+
+// backgroundColour returns the window background for the active theme
+func backgroundColour() *options.RGBA {
+	if isDark() {
+		return {{rgbaLiteral .DarkBackground}}
+	}
+	return {{rgbaLiteral .LightBackground}}
+}
+
+// themeScript returns the JavaScript that applies the theme to the page
+func themeScript() string {
+	theme, _ := json.Marshal(appTheme)
+	light, _ := json.Marshal(lightBackground)
+	dark, _ := json.Marshal(darkBackground)
+
+	return fmt.Sprintf(` + "`" + `
+		(function() {
+			var theme = %s;
+			var light = %s;
+			var dark = %s;
+			var filter = %t;
+			var media = window.matchMedia('(prefers-color-scheme: dark)');
+			var style = null;
+
+			function apply() {
+				var isDark = theme === 'dark' || (theme === 'system' && media.matches);
+
+				// 反色滤镜会把浅色背景变成深色
+				var background = isDark && !filter ? dark : light;
+				document.documentElement.style.colorScheme = isDark && !filter ? 'dark' : 'light';
+				document.documentElement.style.backgroundColor = background;
+				if (document.body) {
+					document.body.style.backgroundColor = background;
+				}
+
+				if (filter && isDark && !style) {
+					style = document.createElement('style');
+					style.id = 'pake-dark-filter';
+					style.textContent = 'html { filter: invert(1) hue-rotate(180deg); } ' +
+						'img, video, picture, canvas, iframe, svg image, [style*="background-image"] { filter: invert(1) hue-rotate(180deg); }';
+					document.head.appendChild(style);
+				} else if (style && !isDark) {
+					style.remove();
+					style = null;
+				}
+			}
+
+			apply();
+			if (theme === 'system') {
+				media.addEventListener('change', apply);
+			}
+		})();
+	` + "`" + `, theme, light, dark, darkFilter)
+}
+`
@@ -0,0 +1,185 @@
+package builder
+
+const updateTemplate = `package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"strconv"
+	"time"
+)
+
+// appVersion is the version of this build, compared with the feed
+const appVersion = {{printf "%q" .AppVersion}}
+
+const (
+	updateFeedURL   = {{printf "%q" .Update.FeedURL}}
+	updateChannel   = {{printf "%q" .Update.EffectiveChannel}}
+	updatePublicKey = {{printf "%q" (publicKeyLine .Update.PublicKey)}}
+	updateInterval  = {{printf "%q" .Update.EffectiveInterval}}
+)
+
+// updateFeed is the part of the feed written by pake-go publish-feed the
+// updater needs
+type updateFeed struct {
+	Channels map[string]struct {
+		Version   string                 ` + "`" + `json:"version"` + "`" + `
+		Platforms map[string]updateAsset ` + "`" + `json:"platforms"` + "`" + `
+	} ` + "`" + `json:"channels"` + "`" + `
+}
+
+// updateAsset is a signed binary in the feed
+type updateAsset struct {
+	URL       string ` + "`" + `json:"url"` + "`" + `
+	SHA256    string ` + "`" + `json:"sha256"` + "`" + `
+	Signature string ` + "`" + `json:"signature"` + "`" + `
+}
+
+// startUpdater checks the feed in the background, shortly after startup and
+// then every updateInterval. A newer release replaces the executable and
+// is used from the next start.
+func startUpdater() {
+	if goruntime.GOOS == "darwin" {
+		// Replacing the executable would break the code signature of the bundle
+		log.Printf("updates are not supported on macOS")
+		return
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		log.Printf("updates disabled: %v", err)
+		return
+	}
+	// The previous executable can only be removed once it is no longer running
+	os.Remove(exe + ".old")
+
+	interval, _ := time.ParseDuration(updateInterval)
+	go func() {
+		time.Sleep(30 * time.Second)
+		for {
+			version, err := checkForUpdate(exe)
+			switch {
+			case err != nil:
+				log.Printf("update check failed: %v", err)
+			case version != "":
+				log.Printf("updated to %s, restart to use it", version)
+				return
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// checkForUpdate installs the release of updateChannel over exe when it is
+// newer than appVersion and returns its version
+func checkForUpdate(exe string) (string, error) {
+	data, err := download(updateFeedURL)
+	if err != nil {
+		return "", err
+	}
+	var feed updateFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return "", fmt.Errorf("invalid feed: %w", err)
+	}
+
+	release, ok := feed.Channels[updateChannel]
+	if !ok || compareVersions(release.Version, appVersion) <= 0 {
+		return "", nil
+	}
+	platform := goruntime.GOOS + "/" + goruntime.GOARCH
+	asset, ok := release.Platforms[platform]
+	if !ok {
+		platform = goruntime.GOOS + "/universal"
+		asset, ok = release.Platforms[platform]
+	}
+	if !ok {
+		return "", nil
+	}
+
+	data, err = download(asset.URL)
+	if err != nil {
+		return "", err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != asset.SHA256 {
+		return "", errors.New("downloaded binary does not match its checksum")
+	}
+	comment, err := verifySignature(updatePublicKey, data, asset.Signature)
+	if err != nil {
+		return "", err
+	}
+	// The feed itself is not signed, so the signed comment must confirm the
+	// version and platform it advertises, e.g. against an old binary offered
+	// as a new version
+	if signed := commentField(comment, "version"); signed != release.Version {
+		return "", fmt.Errorf("binary is signed for version %q, not %q", signed, release.Version)
+	}
+	if signed := commentField(comment, "platform"); signed != platform {
+		return "", fmt.Errorf("binary is signed for platform %q, not %q", signed, platform)
+	}
+
+	if err := replaceExecutable(exe, data); err != nil {
+		return "", err
+	}
+	return release.Version, nil
+}
+
+// replaceExecutable writes data next to exe and swaps it in with renames.
+// A running executable can be renamed on every platform.
+func replaceExecutable(exe string, data []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	next := exe + ".new"
+	if err := os.WriteFile(next, data, info.Mode().Perm()|0700); err != nil {
+		return fmt.Errorf("cannot write next to the executable: %w", err)
+	}
+
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		os.Remove(next)
+		return err
+	}
+	if err := os.Rename(next, exe); err != nil {
+		os.Rename(old, exe)
+		os.Remove(next)
+		return err
+	}
+	return nil
+}
+
+// versionNumber matches the numeric part of a version such as v1.2.3-rc1
+var versionNumber = regexp.MustCompile(` + "`" + `^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?` + "`" + `)
+
+// compareVersions compares the numeric parts of two versions
+func compareVersions(a, b string) int {
+	pa, pb := versionNumber.FindStringSubmatch(a), versionNumber.FindStringSubmatch(b)
+	for i := 1; i <= 3; i++ {
+		var na, nb int
+		if pa != nil {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if pb != nil {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+`
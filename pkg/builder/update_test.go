@@ -0,0 +1,132 @@
+package builder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/signing"
+	"github.com/zk3151463/pake-go/pkg/update"
+)
+
+// updaterHarness runs the generated updater once against the executable
+// named on the command line
+const updaterHarness = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	version, err := checkForUpdate(os.Args[1])
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	fmt.Print("version:", version)
+}
+`
+
+func TestUpdaterEndToEnd(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not in PATH")
+	}
+
+	key, err := signing.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDir := t.TempDir()
+	writeFiles(t, keyDir, map[string]string{"pake.pub": string(key.Public().Encode())})
+
+	// Publish version 1.1.0 of the app for this platform
+	outputDir := t.TempDir()
+	writeFiles(t, outputDir, map[string]string{"bin/App": "new binary"})
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(outputDir)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	feed := &update.Feed{Channels: map[string]*update.Release{}}
+	if _, err := update.Publish(feed, &config.Config{Name: "App", Version: "1.1.0"}, update.Options{
+		OutputDir:    outputDir,
+		BaseURL:      server.URL,
+		HostPlatform: runtime.GOOS + "/" + runtime.GOARCH,
+		Key:          key,
+	}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if err := feed.Save(filepath.Join(outputDir, "feed.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Render the updater of version 1.0.0 into a program checking once
+	cfg := config.DefaultConfig()
+	cfg.Version = "1.0.0"
+	cfg.Update = &config.UpdateConfig{
+		FeedURL:   server.URL + "/feed.json",
+		PublicKey: filepath.Join(keyDir, "pake.pub"),
+	}
+	projectDir := t.TempDir()
+	for _, name := range []string{"update.go", "download.go"} {
+		if err := NewBuilder(cfg).writeProjectFile(projectDir, name); err != nil {
+			t.Fatalf("Failed to generate %s: %v", name, err)
+		}
+	}
+	writeFiles(t, projectDir, map[string]string{
+		"go.mod":  "module app\n\ngo 1.21\n",
+		"main.go": updaterHarness,
+	})
+	harness := filepath.Join(t.TempDir(), "harness")
+	build := exec.Command("go", "build", "-o", harness, ".")
+	build.Dir = projectDir
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build the updater: %v\n%s", err, out)
+	}
+
+	exe := filepath.Join(t.TempDir(), "App")
+	writeFiles(t, filepath.Dir(exe), map[string]string{"App": "old binary"})
+	out, err := exec.Command(harness, exe).CombinedOutput()
+	if err != nil || string(out) != "version:1.1.0" {
+		t.Fatalf("Expected the update to install, got %v: %s", err, out)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "new binary" {
+		t.Errorf("Expected the executable to be replaced, got %q", data)
+	}
+	if data, _ := os.ReadFile(exe + ".old"); string(data) != "old binary" {
+		t.Errorf("Expected the previous executable to be kept until restart, got %q", data)
+	}
+
+	// The signed version of a binary must match the one the feed advertises
+	release := feed.Channels[config.DefaultUpdateChannel]
+	release.Version = "99.0.0"
+	if err := feed.Save(filepath.Join(outputDir, "feed.json")); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = exec.Command(harness, exe).CombinedOutput()
+	if !strings.Contains(string(out), `signed for version "1.1.0"`) {
+		t.Errorf("Expected an old binary offered as a new version to be rejected, got %s", out)
+	}
+
+	// A binary signed with another key is rejected
+	other, _ := signing.GenerateKey()
+	for _, asset := range release.Platforms {
+		asset.Signature = string(signing.Sign(other, []byte("new binary"), "file:App"))
+	}
+	release.Version = "1.2.0"
+	if err := feed.Save(filepath.Join(outputDir, "feed.json")); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = exec.Command(harness, exe).CombinedOutput()
+	if !strings.Contains(string(out), "different key") {
+		t.Errorf("Expected a release signed with another key to be rejected, got %s", out)
+	}
+}
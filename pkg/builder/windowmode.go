@@ -0,0 +1,52 @@
+package builder
+
+const windowsModeTemplate = `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// childWindows are the instances started by OpenWindow
+var childWindows sync.WaitGroup
+
+// OpenWindow opens url in a new native window by starting another instance
+// of the app, since Wails v2 only manages a single window per process. An
+// incognito instance shares its temporary profile with the new one.
+func (a *App) OpenWindow(url string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("unsupported url: %s", url)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-pake-url=" + url}
+	if incognito {
+		args = append(args, profileArg+activeProfile)
+	}
+	cmd := hostCommand(exe, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	childWindows.Add(1)
+	go func() {
+		cmd.Wait()
+		childWindows.Done()
+	}()
+
+	return nil
+}
+
+// waitForWindows keeps an incognito instance, and so its temporary profile,
+// until the windows it opened are closed
+func waitForWindows() {
+	if incognito {
+		childWindows.Wait()
+	}
+}
+`
@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+// linkHarness runs the domReady script of main.go on a fake remote page,
+// clicks a target=_blank link, calls window.open and prints what happened
+const linkHarness = `const fs = require('fs');
+const vm = require('vm');
+
+const listeners = {};
+const page = {
+	URL: URL,
+	Intl: Intl,
+	navigator: {},
+	screen: {},
+	sessionStorage: { items: {}, getItem(k) { return this.items[k] || null; }, setItem(k, v) { this.items[k] = v; } },
+	location: { href: 'https://example.com/start', protocol: 'https:', hostname: 'example.com' },
+	document: { addEventListener(type, fn) { listeners[type] = fn; } },
+	open() { console.log('native open'); },
+	go: { main: { App: { OpenWindow(url) { console.log('window ' + url); } } } },
+	pakeOpenTab(url) { console.log('tab ' + url); },
+};
+page.window = page;
+vm.createContext(page);
+vm.runInContext(fs.readFileSync(process.argv[2], 'utf8'), page);
+console.log('load ' + page.location.href);
+
+const link = { tagName: 'A', parentElement: null, getAttribute(name) { return { href: 'https://example.com/doc', target: '_blank' }[name]; } };
+let prevented = false;
+listeners.click({ target: link, preventDefault() { prevented = true; }, stopPropagation() {} });
+console.log('click ' + page.location.href + ' ' + prevented);
+
+page.open('https://example.com/popup');
+console.log('open ' + page.location.href);
+`
+
+// domReadyScript returns the script domReady runs on every page for cfg
+func domReadyScript(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+	projectDir := t.TempDir()
+	if err := NewBuilder(cfg).writeProjectFile(projectDir, "main.go"); err != nil {
+		t.Fatalf("Failed to generate main.go: %v", err)
+	}
+	mainGo, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, script, ok := strings.Cut(string(mainGo), "script := fmt.Sprintf(`")
+	script, _, ok2 := strings.Cut(script, "`, target)")
+	if !ok || !ok2 {
+		t.Fatalf("No domReady script in main.go:\n%s", mainGo)
+	}
+	target, _ := json.Marshal(cfg.URL)
+	return fmt.Sprintf(script, target)
+}
+
+func TestLinkInterception(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node not in PATH")
+	}
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{config.WindowModeSingle, "load https://example.com/start\n" +
+			"click https://example.com/doc true\n" +
+			"open https://example.com/popup\n"},
+		{config.WindowModeWindows, "load https://example.com/start\n" +
+			"window https://example.com/doc\n" +
+			"click https://example.com/start true\n" +
+			"window https://example.com/popup\n" +
+			"open https://example.com/start\n"},
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"harness.js": linkHarness})
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.URL = "https://example.com/"
+		cfg.WindowMode = tt.mode
+		writeFiles(t, dir, map[string]string{"script.js": domReadyScript(t, cfg)})
+
+		out, err := exec.Command("node", filepath.Join(dir, "harness.js"), filepath.Join(dir, "script.js")).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s mode: failed to run the script: %v\n%s", tt.mode, err, out)
+		}
+		if string(out) != tt.want {
+			t.Errorf("%s mode: expected\n%s\ngot\n%s", tt.mode, tt.want, out)
+		}
+	}
+}
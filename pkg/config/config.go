@@ -2,8 +2,15 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zk3151463/pake-go/webview"
 )
 
 // Config represents the application configuration
@@ -20,8 +27,307 @@ type Config struct {
 	Headers      map[string]string `json:"headers"`
 	InjectCSS    []string          `json:"injectCSS"`
 	InjectJS     []string          `json:"injectJS"`
+	// InjectRules inject CSS and JS into the pages they match
+	InjectRules []InjectRule `json:"injectRules"`
+	// Assets are files, directories or globs bundled into the app and served
+	// under /pake-assets/
+	Assets          []string       `json:"assets"`
+	Badge           *BadgeConfig   `json:"badge,omitempty"`
+	WindowMode      string         `json:"windowMode"`
+	Proxy           *ProxyConfig   `json:"proxy,omitempty"`
+	DataDir         string         `json:"dataDir"`
+	Incognito       bool           `json:"incognito"`
+	Theme           string         `json:"theme"`
+	BackgroundColor string         `json:"backgroundColor"`
+	DarkFilter      bool           `json:"darkFilter"`
+	Version         string         `json:"version"`
+	Description     string         `json:"description"`
+	Package         *PackageConfig `json:"package,omitempty"`
+	Identifier      string         `json:"identifier"`
+	Company         string         `json:"company"`
+	Copyright       string         `json:"copyright"`
+	Author          string         `json:"author"`
+	Platforms       []string       `json:"platforms"`
+	// WebKit is the WebKitGTK API Linux apps are built against
+	WebKit string `json:"webkit"`
+	// TemplatesDir holds templates that replace the built-in ones for the
+	// generated files of the same relative path
+	TemplatesDir string `json:"templatesDir"`
+	// Hooks are commands run at phases of the build
+	Hooks *HooksConfig `json:"hooks,omitempty"`
+	// Signing writes checksums and signatures of the artifacts
+	Signing *SigningConfig `json:"signing,omitempty"`
+	// Update makes the app update itself from a release feed
+	Update *UpdateConfig `json:"update,omitempty"`
+	// Rules makes the app fetch injection rules from a signed bundle
+	Rules *RulesConfig `json:"rules,omitempty"`
+
+	// BuildTime is the time recorded in the app metadata; zero uses the
+	// current time. Reproducible builds set it from the lock file.
+	BuildTime time.Time `json:"-"`
+}
+
+// PackageConfig describes the Linux packages produced after a build
+type PackageConfig struct {
+	// Targets lists the package formats to produce: deb, rpm and tar.gz
+	Targets    []string `json:"targets"`
+	Maintainer string   `json:"maintainer"`
+	// Categories are freedesktop.org menu categories, e.g. Network or Office
+	Categories []string `json:"categories"`
+	Homepage   string   `json:"homepage"`
+	License    string   `json:"license"`
+	// Depends overrides the default runtime dependencies of deb and rpm packages
+	Depends []string `json:"depends"`
+}
+
+// HooksConfig lists shell commands run at phases of the build. A failing
+// command aborts the build.
+type HooksConfig struct {
+	// PreGenerate runs before the project is generated
+	PreGenerate []string `json:"preGenerate"`
+	// PostGenerate runs after the project is generated, before it is built
+	PostGenerate []string `json:"postGenerate"`
+	// PreSign runs after every app is built and packaged, before the
+	// artifacts are checksummed and signed
+	PreSign []string `json:"preSign"`
+	// PostBuild runs after the artifacts are signed
+	PostBuild []string `json:"postBuild"`
+}
+
+// SigningConfig describes how artifacts are signed after a build. A
+// SHA256SUMS file is always written.
+type SigningConfig struct {
+	// Key is the secret key file signing SHA256SUMS, created with pake-go
+	// keygen. The key can also be passed in PAKE_SIGNING_KEY.
+	Key string `json:"key"`
+	// Signers maps an OS to a command signing each artifact built for it,
+	// e.g. signtool on windows. The artifact is passed in PAKE_ARTIFACT.
+	Signers map[string]string `json:"signers"`
+}
+
+// UpdateConfig describes the release feed generated apps update from
+type UpdateConfig struct {
+	// FeedURL is the feed written by pake-go publish-feed
+	FeedURL string `json:"feedURL"`
+	// Channel selects the release channel in the feed, e.g. beta
+	Channel string `json:"channel"`
+	// PublicKey is the public key file releases are signed with; it is
+	// compiled into the app
+	PublicKey string `json:"publicKey"`
+	// Interval is the time between update checks, e.g. 30m
+	Interval string `json:"interval"`
+}
+
+// DefaultUpdateChannel is used when no update channel is configured
+const DefaultUpdateChannel = "stable"
+
+// DefaultUpdateInterval is used when no update interval is configured
+const DefaultUpdateInterval = "6h"
+
+// EffectiveChannel returns the configured channel or the default one
+func (u *UpdateConfig) EffectiveChannel() string {
+	if u.Channel == "" {
+		return DefaultUpdateChannel
+	}
+	return u.Channel
+}
+
+// EffectiveInterval returns the configured interval or the default one
+func (u *UpdateConfig) EffectiveInterval() string {
+	if u.Interval == "" {
+		return DefaultUpdateInterval
+	}
+	return u.Interval
+}
+
+// Validate checks the feed URL, key and interval
+func (u *UpdateConfig) Validate() error {
+	feed, err := url.Parse(u.FeedURL)
+	if err != nil {
+		return err
+	}
+	// The feed is not signed, so it must not be served over plain http
+	if feed.Scheme != "https" || feed.Host == "" {
+		return fmt.Errorf("feed url %q is not an https url", u.FeedURL)
+	}
+	if u.PublicKey == "" {
+		return fmt.Errorf("no public key to verify releases")
+	}
+	interval, err := time.ParseDuration(u.EffectiveInterval())
+	if err != nil {
+		return fmt.Errorf("invalid interval: %w", err)
+	}
+	if interval < time.Minute {
+		return fmt.Errorf("interval %s is shorter than a minute", interval)
+	}
+	return nil
+}
+
+// InjectRule injects CSS and JS into the pages it matches, like a rule of
+// the rules bundle
+type InjectRule struct {
+	// Match is the match type of URL: exact, prefix, glob, regex, url or
+	// legacy, the default
+	Match string `json:"match"`
+	URL   string `json:"url"`
+	// Host, Path and Query are matched by the url match type
+	Host  string            `json:"host"`
+	Path  string            `json:"path"`
+	Query map[string]string `json:"query"`
+	CSS   []string          `json:"css"`
+	JS    []string          `json:"js"`
+}
+
+// Injects reports whether generated apps customize pages with injection
+// rules, built in or fetched
+func (c *Config) Injects() bool {
+	return len(c.InjectCSS) > 0 || len(c.InjectJS) > 0 || len(c.InjectRules) > 0 || c.Rules != nil
+}
+
+// EffectiveMatch returns the configured match type or legacy
+func (r *InjectRule) EffectiveMatch() string {
+	if r.Match == "" {
+		return string(webview.MatchLegacy)
+	}
+	return r.Match
+}
+
+// Validate checks the matcher of the rule
+func (r *InjectRule) Validate() error {
+	var err error
+	if match := webview.MatchType(r.EffectiveMatch()); match == webview.MatchURL {
+		_, err = webview.NewURLMatcher(r.Host, r.Path, r.Query)
+	} else {
+		_, err = webview.NewMatcher(match, r.URL)
+	}
+	return err
+}
+
+// RulesConfig describes the signed injection rules bundle generated apps
+// fetch at startup and periodically
+type RulesConfig struct {
+	// URL is the rules bundle; its signature is read from URL + ".sig"
+	URL string `json:"url"`
+	// PublicKey is the public key file the bundle is signed with; it is
+	// compiled into the app
+	PublicKey string `json:"publicKey"`
+	// Interval is the time between fetches, e.g. 30m
+	Interval string `json:"interval"`
 }
 
+// DefaultRulesInterval is used when no rules interval is configured
+const DefaultRulesInterval = "1h"
+
+// EffectiveInterval returns the configured interval or the default one
+func (r *RulesConfig) EffectiveInterval() string {
+	if r.Interval == "" {
+		return DefaultRulesInterval
+	}
+	return r.Interval
+}
+
+// Validate checks the bundle URL, key and interval
+func (r *RulesConfig) Validate() error {
+	bundle, err := url.Parse(r.URL)
+	if err != nil {
+		return err
+	}
+	if (bundle.Scheme != "http" && bundle.Scheme != "https") || bundle.Host == "" {
+		return fmt.Errorf("rules url %q is not an http or https url", r.URL)
+	}
+	if r.PublicKey == "" {
+		return fmt.Errorf("no public key to verify the rules")
+	}
+	interval, err := time.ParseDuration(r.EffectiveInterval())
+	if err != nil {
+		return fmt.Errorf("invalid interval: %w", err)
+	}
+	if interval < time.Minute {
+		return fmt.Errorf("interval %s is shorter than a minute", interval)
+	}
+	return nil
+}
+
+// Linux package formats
+const (
+	PackageDeb     = "deb"
+	PackageRPM     = "rpm"
+	PackageTarball = "tar.gz"
+)
+
+// WebKitGTK APIs Linux apps can be built against. Wails links 4.0 unless
+// built with the webkit2_41 tag.
+const (
+	WebKit40 = "4.0"
+	WebKit41 = "4.1"
+)
+
+// DefaultVersion is used when no version is configured
+const DefaultVersion = "1.0.0"
+
+// VersionFromGit derives the version from `git describe` at build time
+const VersionFromGit = "git"
+
+var (
+	identifierPattern     = regexp.MustCompile(`^[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+	identifierInvalidChar = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+	numericVersionPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+	authorPattern         = regexp.MustCompile(`^\s*([^<]*?)\s*(?:<([^>]*)>)?\s*$`)
+	platformPattern       = regexp.MustCompile(`^(darwin/(amd64|arm64|universal)|linux/(amd64|arm64|arm|386)|windows/(amd64|arm64|386))$`)
+)
+
+// Themes supported by generated apps
+const (
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system"
+)
+
+// Default background colours for light and dark themes
+const (
+	DefaultLightBackground = "#ffffff"
+	DefaultDarkBackground  = "#1e1e1e"
+)
+
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ProxyConfig describes the network proxy used by the generated app. When
+// neither URL nor PAC is set the system proxy settings are used.
+type ProxyConfig struct {
+	// URL is the proxy server, e.g. http://proxy:3128 or socks5://proxy:1080
+	URL string `json:"url"`
+	// Bypass lists hosts that are reached directly, e.g. localhost or *.corp.example.com
+	Bypass []string `json:"bypass"`
+	// PAC is the URL of a proxy auto-config file; it takes precedence over URL
+	PAC string `json:"pac"`
+}
+
+// IconAuto discovers the icon from the target site instead of a local file
+const IconAuto = "auto"
+
+// Window modes supported by generated apps
+const (
+	// WindowModeSingle keeps every navigation in the main window
+	WindowModeSingle = "single"
+	// WindowModeWindows opens target=_blank links in new native windows
+	WindowModeWindows = "windows"
+)
+
+// BadgeConfig describes how an unread counter is extracted from the page title
+type BadgeConfig struct {
+	// Pattern is a regular expression matched against document.title; the
+	// first capture group (or the whole match) is used as the badge text
+	Pattern string `json:"pattern"`
+	// Expression is a JavaScript expression evaluated in the page; when set
+	// it takes precedence over Pattern
+	Expression string `json:"expression"`
+	// Title mirrors the counter into the native window title
+	Title bool `json:"title"`
+}
+
+// DefaultBadgePattern matches titles such as "(3) Inbox"
+const DefaultBadgePattern = `^\((\d+)\)`
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -37,6 +343,269 @@ func DefaultConfig() *Config {
 	}
 }
 
+// EffectivePattern returns the configured title pattern or the default one
+func (b *BadgeConfig) EffectivePattern() string {
+	if b.Pattern == "" {
+		return DefaultBadgePattern
+	}
+	return b.Pattern
+}
+
+// jsOnlyGroup matches the groups Go accepts but JavaScript does not: inline
+// flags such as (?i) and (?P<name>
+var jsOnlyGroup = regexp.MustCompile(`(^|[^\\])\(\?[A-Za-z]`)
+
+// Validate checks that the title pattern compiles both in Go and in
+// JavaScript, where it runs
+func (b *BadgeConfig) Validate() error {
+	if b.Expression != "" {
+		return nil
+	}
+	pattern := b.EffectivePattern()
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if jsOnlyGroup.MatchString(pattern) {
+		return fmt.Errorf("invalid pattern %q: inline flags and (?P< are not supported by JavaScript", pattern)
+	}
+	return nil
+}
+
+// AppVersion returns the configured version or the default one
+func (c *Config) AppVersion() string {
+	if c.Version != "" {
+		return c.Version
+	}
+	return DefaultVersion
+}
+
+// NumericVersion returns the version as major.minor.patch, as required by
+// Windows version resources and macOS bundle versions
+func (c *Config) NumericVersion() string {
+	match := numericVersionPattern.FindStringSubmatch(c.AppVersion())
+	if match == nil {
+		return "0.0.0"
+	}
+
+	parts := match[1:]
+	for i, part := range parts {
+		if part == "" {
+			parts[i] = "0"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// BundleIdentifier returns the reverse-DNS bundle identifier of the app
+func (c *Config) BundleIdentifier() string {
+	if c.Identifier != "" {
+		return c.Identifier
+	}
+
+	name := strings.Trim(identifierInvalidChar.ReplaceAllString(c.Name, "-"), "-")
+	if name == "" {
+		name = "app"
+	}
+	return "com.wails." + name
+}
+
+// CompanyName returns the company the app is published by
+func (c *Config) CompanyName() string {
+	if c.Company != "" {
+		return c.Company
+	}
+	return c.Name
+}
+
+// CopyrightNotice returns the copyright string of the app
+func (c *Config) CopyrightNotice() string {
+	if c.Copyright != "" {
+		return c.Copyright
+	}
+	return fmt.Sprintf("Copyright © %d %s", c.Timestamp().Year(), c.CompanyName())
+}
+
+// Timestamp returns BuildTime, or the current time when it is unset
+func (c *Config) Timestamp() time.Time {
+	if !c.BuildTime.IsZero() {
+		return c.BuildTime
+	}
+	return time.Now()
+}
+
+// AuthorName returns the name part of an "Name <email>" author
+func (c *Config) AuthorName() string {
+	if match := authorPattern.FindStringSubmatch(c.Author); match != nil && match[1] != "" {
+		return match[1]
+	}
+	return c.CompanyName()
+}
+
+// AuthorEmail returns the email part of an "Name <email>" author
+func (c *Config) AuthorEmail() string {
+	if match := authorPattern.FindStringSubmatch(c.Author); match != nil {
+		return match[2]
+	}
+	return ""
+}
+
+// ProfileDir returns the webview data directory of the generated app.
+// Relative paths are resolved against the user config dir at runtime.
+func (c *Config) ProfileDir() string {
+	if c.DataDir != "" {
+		return c.DataDir
+	}
+
+	name := c.Name
+	if name == "" {
+		name = "pake-app"
+	}
+	return filepath.Join("pake-go", name)
+}
+
+// LightBackground returns the background colour used in light mode
+func (c *Config) LightBackground() string {
+	if c.BackgroundColor != "" {
+		return c.BackgroundColor
+	}
+	return DefaultLightBackground
+}
+
+// DarkBackground returns the background colour used in dark mode
+func (c *Config) DarkBackground() string {
+	if c.BackgroundColor != "" {
+		return c.BackgroundColor
+	}
+	return DefaultDarkBackground
+}
+
+// ThemeBackground returns the background colour of the configured theme,
+// using the light colour for the system theme until the page can tell
+func (c *Config) ThemeBackground() string {
+	if c.Theme == ThemeDark {
+		return c.DarkBackground()
+	}
+	return c.LightBackground()
+}
+
+// Validate checks the configuration for unsupported values
+func (c *Config) Validate() error {
+	switch c.WindowMode {
+	case "", WindowModeSingle, WindowModeWindows:
+	case "tabs":
+		// A tab strip in the single webview of the app reloads a tab on
+		// every switch, losing its scroll position, form input and state
+		return fmt.Errorf(`window mode "tabs" is not supported, use "windows" to keep documents open in their own windows`)
+	default:
+		return fmt.Errorf("unsupported window mode %q", c.WindowMode)
+	}
+
+	switch c.Theme {
+	case "", ThemeLight, ThemeDark, ThemeSystem:
+	default:
+		return fmt.Errorf("unsupported theme %q", c.Theme)
+	}
+
+	if c.BackgroundColor != "" && !hexColorPattern.MatchString(c.BackgroundColor) {
+		return fmt.Errorf("invalid background color %q, expected #rgb or #rrggbb", c.BackgroundColor)
+	}
+
+	if c.Identifier != "" && !identifierPattern.MatchString(c.Identifier) {
+		return fmt.Errorf("invalid identifier %q, expected reverse-DNS such as com.example.app", c.Identifier)
+	}
+
+	if c.Package != nil {
+		for _, target := range c.Package.Targets {
+			switch target {
+			case PackageDeb, PackageRPM, PackageTarball:
+			default:
+				return fmt.Errorf("unsupported package target %q", target)
+			}
+		}
+	}
+
+	switch c.WebKit {
+	case "", WebKit40, WebKit41:
+	default:
+		return fmt.Errorf("unsupported webkit %q, expected %s or %s", c.WebKit, WebKit40, WebKit41)
+	}
+
+	for _, platform := range c.Platforms {
+		if !platformPattern.MatchString(platform) {
+			return fmt.Errorf("unsupported platform %q, expected os/arch such as linux/amd64", platform)
+		}
+	}
+
+	if c.Badge != nil {
+		if err := c.Badge.Validate(); err != nil {
+			return fmt.Errorf("invalid badge: %w", err)
+		}
+	}
+
+	if c.Proxy != nil {
+		if err := c.Proxy.Validate(); err != nil {
+			return fmt.Errorf("invalid proxy: %w", err)
+		}
+	}
+
+	if c.Update != nil {
+		if err := c.Update.Validate(); err != nil {
+			return fmt.Errorf("invalid update config: %w", err)
+		}
+	}
+
+	if c.Rules != nil {
+		if err := c.Rules.Validate(); err != nil {
+			return fmt.Errorf("invalid rules config: %w", err)
+		}
+	}
+
+	for i := range c.InjectRules {
+		if err := c.InjectRules[i].Validate(); err != nil {
+			return fmt.Errorf("invalid inject rule %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Enabled reports whether an explicit proxy is configured
+func (p *ProxyConfig) Enabled() bool {
+	return p.URL != "" || p.PAC != ""
+}
+
+// Validate checks the proxy URLs
+func (p *ProxyConfig) Validate() error {
+	if p.URL != "" {
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return err
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("proxy url %q has no host", p.URL)
+		}
+	}
+
+	if p.PAC != "" {
+		u, err := url.Parse(p.PAC)
+		if err != nil {
+			return err
+		}
+		switch u.Scheme {
+		case "http", "https", "file":
+		default:
+			return fmt.Errorf("unsupported PAC scheme %q", u.Scheme)
+		}
+	}
+
+	return nil
+}
+
 // LoadConfig loads the configuration from a file
 func LoadConfig(path string) (*Config, error) {
 	config := DefaultConfig()
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -102,3 +103,159 @@ func TestSaveConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	for _, mode := range []string{"", WindowModeSingle, WindowModeWindows} {
+		config := &Config{WindowMode: mode}
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected window mode %q to be valid, got %v", mode, err)
+		}
+	}
+
+	for _, mode := range []string{"popup", "tabs"} {
+		config := &Config{WindowMode: mode}
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected error for unsupported window mode %q", mode)
+		}
+	}
+
+	config := &Config{Theme: ThemeSystem, BackgroundColor: "#1e1e1e"}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected system theme to be valid, got %v", err)
+	}
+
+	config = &Config{Theme: "sepia"}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for unsupported theme")
+	}
+
+	config = &Config{BackgroundColor: "white"}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for invalid background color")
+	}
+
+	config = &Config{Platforms: []string{"linux/amd64", "linux/arm64", "windows/amd64", "darwin/universal"}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected platforms to be valid, got %v", err)
+	}
+
+	config = &Config{Platforms: []string{"linux/universal"}}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for unsupported platform")
+	}
+
+	config = &Config{Update: &UpdateConfig{FeedURL: "https://dl.example.com/feed.json", PublicKey: "pake.pub"}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected update config to be valid, got %v", err)
+	}
+
+	for _, update := range []*UpdateConfig{
+		{FeedURL: "ftp://dl.example.com/feed.json", PublicKey: "pake.pub"},
+		{FeedURL: "http://dl.example.com/feed.json", PublicKey: "pake.pub"},
+		{FeedURL: "https://dl.example.com/feed.json"},
+		{FeedURL: "https://dl.example.com/feed.json", PublicKey: "pake.pub", Interval: "10s"},
+	} {
+		config = &Config{Update: update}
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected error for update config %+v", update)
+		}
+	}
+
+	config = &Config{Rules: &RulesConfig{URL: "https://dl.example.com/rules.json", PublicKey: "pake.pub"}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected rules config to be valid, got %v", err)
+	}
+
+	config = &Config{Rules: &RulesConfig{URL: "rules.json", PublicKey: "pake.pub"}}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for a relative rules url")
+	}
+
+	for _, pattern := range []string{"", `^\[(\d+)\]`, `\(?(\d+)`, `(?<count>\d+)`} {
+		config = &Config{Badge: &BadgeConfig{Pattern: pattern}}
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected badge pattern %q to be valid, got %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"(", `(?i)inbox (\d+)`, `(?P<count>\d+)`} {
+		config = &Config{Badge: &BadgeConfig{Pattern: pattern}}
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected error for badge pattern %q", pattern)
+		}
+	}
+	config = &Config{Badge: &BadgeConfig{Pattern: "(", Expression: "window.unread"}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected the pattern to be ignored with an expression, got %v", err)
+	}
+
+	config = &Config{WebKit: "4.2"}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for an unsupported webkit")
+	}
+
+	config = &Config{InjectRules: []InjectRule{
+		{URL: "example.com"},
+		{Match: "glob", URL: "*.example.com:8080/app/*"},
+		{Match: "url", Host: "example.com", Path: "/app/*", Query: map[string]string{"tab": "edit"}},
+	}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected inject rules to be valid, got %v", err)
+	}
+
+	for _, rule := range []InjectRule{
+		{Match: "regex", URL: "("},
+		{Match: "glob", URL: "/app/*"},
+		{Match: "url", Host: "example.com/app"},
+		{Match: "fuzzy", URL: "example.com"},
+	} {
+		config = &Config{InjectRules: []InjectRule{rule}}
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected error for inject rule %+v", rule)
+		}
+	}
+}
+
+func TestProfileDir(t *testing.T) {
+	config := &Config{Name: "TestApp"}
+	if dir := config.ProfileDir(); dir != filepath.Join("pake-go", "TestApp") {
+		t.Errorf("Expected default profile dir under pake-go, got %s", dir)
+	}
+
+	config.DataDir = "/var/lib/testapp"
+	if dir := config.ProfileDir(); dir != "/var/lib/testapp" {
+		t.Errorf("Expected explicit data dir, got %s", dir)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	config := &Config{Name: "Team Wiki"}
+	if v := config.NumericVersion(); v != DefaultVersion {
+		t.Errorf("Expected default version, got %s", v)
+	}
+	if id := config.BundleIdentifier(); id != "com.wails.Team-Wiki" {
+		t.Errorf("Unexpected default identifier %s", id)
+	}
+	if company := config.CompanyName(); company != "Team Wiki" {
+		t.Errorf("Expected company to default to the app name, got %s", company)
+	}
+
+	config.Version = "v2.3-4-gabcdef"
+	if v := config.NumericVersion(); v != "2.3.0" {
+		t.Errorf("Expected numeric version 2.3.0, got %s", v)
+	}
+
+	config.Author = "Jane Doe <jane@example.com>"
+	if config.AuthorName() != "Jane Doe" || config.AuthorEmail() != "jane@example.com" {
+		t.Errorf("Failed to parse author, got %q <%q>", config.AuthorName(), config.AuthorEmail())
+	}
+
+	config.BuildTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if notice := config.CopyrightNotice(); notice != "Copyright © 2020 Team Wiki" {
+		t.Errorf("Expected copyright from the build time, got %s", notice)
+	}
+
+	config.Identifier = "not an identifier"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for invalid identifier")
+	}
+}
@@ -0,0 +1,316 @@
+package favicon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zk3151463/pake-go/pkg/icon"
+)
+
+// CacheTTL is how long a discovered icon is reused without refetching
+const CacheTTL = 7 * 24 * time.Hour
+
+// maxIconBytes limits the size of downloaded pages, manifests and icons
+const maxIconBytes = 10 << 20
+
+var (
+	linkPattern      = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	attributePattern = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	sizesPattern     = regexp.MustCompile(`(\d+)[xX](\d+)`)
+)
+
+// Candidate is an icon advertised by a page
+type Candidate struct {
+	URL  string
+	Size int
+}
+
+// Finder discovers the highest resolution icon of a site
+type Finder struct {
+	Client   *http.Client
+	CacheDir string
+	// Offline only returns cached icons for remote sites
+	Offline bool
+}
+
+// NewFinder creates a Finder. An empty cacheDir uses the user cache dir.
+func NewFinder(client *http.Client, cacheDir string) *Finder {
+	if cacheDir == "" {
+		if base, err := os.UserCacheDir(); err == nil {
+			cacheDir = filepath.Join(base, "pake-go", "icons")
+		}
+	}
+
+	return &Finder{
+		Client:   client,
+		CacheDir: cacheDir,
+	}
+}
+
+// Fetch returns the path of the best icon for pageURL. Fresh cached icons are
+// reused, and a stale cached icon is returned when the site is unreachable.
+func (f *Finder) Fetch(pageURL string) (string, error) {
+	return f.cached(pageURL, func() ([]Candidate, error) {
+		return f.Candidates(pageURL)
+	})
+}
+
+// FetchCandidates returns the path of the largest usable icon among
+// candidates, cached like Fetch under the site of key
+func (f *Finder) FetchCandidates(key string, candidates []Candidate) (string, error) {
+	return f.cached(key, func() ([]Candidate, error) {
+		return candidates, nil
+	})
+}
+
+// cached returns the cached icon for key or downloads the best candidate
+func (f *Finder) cached(key string, candidates func() ([]Candidate, error)) (string, error) {
+	cachePath := f.cachePath(key)
+	if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < CacheTTL {
+		return cachePath, nil
+	}
+
+	if f.Offline && (strings.HasPrefix(key, "http://") || strings.HasPrefix(key, "https://")) {
+		if _, err := os.Stat(cachePath); err == nil {
+			return cachePath, nil
+		}
+		return "", fmt.Errorf("no cached icon for %s while offline", key)
+	}
+
+	data, err := f.download(candidates)
+	if err != nil {
+		if _, statErr := os.Stat(cachePath); statErr == nil {
+			return cachePath, nil
+		}
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		return "", err
+	}
+
+	return cachePath, nil
+}
+
+// cachePath returns the cache file of the site hosting pageURL
+func (f *Finder) cachePath(pageURL string) string {
+	key := pageURL
+	if u, err := url.Parse(pageURL); err == nil && u.Host != "" {
+		key = u.Scheme + "://" + u.Host
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.CacheDir, hex.EncodeToString(sum[:8])+".icon")
+}
+
+// download fetches every candidate icon and returns the largest decodable one
+func (f *Finder) download(list func() ([]Candidate, error)) ([]byte, error) {
+	candidates, err := list()
+	if err != nil {
+		return nil, err
+	}
+
+	var best []byte
+	bestSize := 0
+	for _, candidate := range candidates {
+		data, err := f.get(candidate.URL)
+		if err != nil {
+			continue
+		}
+		size, err := decodeWidth(data)
+		if err != nil {
+			continue
+		}
+		if size > bestSize {
+			best, bestSize = data, size
+		}
+	}
+
+	if best == nil {
+		return nil, errors.New("no usable icon found")
+	}
+	return best, nil
+}
+
+// decodeWidth returns the width of an icon
+func decodeWidth(data []byte) (int, error) {
+	img, err := icon.Decode(data)
+	if err != nil {
+		return 0, err
+	}
+	return img.Bounds().Dx(), nil
+}
+
+// fetchable reports whether ref, found in a document at base, may be
+// fetched. Local files are only read for local documents.
+func fetchable(base *url.URL, ref *url.URL) bool {
+	switch ref.Scheme {
+	case "http", "https":
+		return true
+	case "file":
+		return base.Scheme == "file"
+	}
+	return false
+}
+
+// Candidates returns the icons advertised by the page, largest declared size first
+func (f *Finder) Candidates(pageURL string) ([]Candidate, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := f.get(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	add := func(href string, size int) {
+		ref, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || !fetchable(base, ref) || seen[ref.String()] {
+			return
+		}
+		seen[ref.String()] = true
+		candidates = append(candidates, Candidate{URL: ref.String(), Size: size})
+	}
+
+	for _, tag := range linkPattern.FindAll(page, -1) {
+		attrs := parseAttributes(string(tag))
+		rel := strings.Fields(strings.ToLower(attrs["rel"]))
+		href := attrs["href"]
+		if href == "" {
+			continue
+		}
+
+		switch {
+		case contains(rel, "manifest"):
+			manifestURL, err := base.Parse(href)
+			if err != nil || !fetchable(base, manifestURL) {
+				continue
+			}
+			for _, c := range f.manifestIcons(manifestURL) {
+				add(c.URL, c.Size)
+			}
+		case contains(rel, "apple-touch-icon") || contains(rel, "apple-touch-icon-precomposed"):
+			size := parseSizes(attrs["sizes"])
+			if size == 0 {
+				// Apple touch icons default to 180x180
+				size = 180
+			}
+			add(href, size)
+		case contains(rel, "icon"):
+			add(href, parseSizes(attrs["sizes"]))
+		}
+	}
+
+	// Every site may serve the legacy favicon
+	add("/favicon.ico", 16)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Size > candidates[j].Size
+	})
+
+	return candidates, nil
+}
+
+// manifestIcons returns the icons listed in a web app manifest
+func (f *Finder) manifestIcons(manifestURL *url.URL) []Candidate {
+	data, err := f.get(manifestURL.String())
+	if err != nil {
+		return nil
+	}
+
+	var manifest struct {
+		Icons []struct {
+			Src   string `json:"src"`
+			Sizes string `json:"sizes"`
+		} `json:"icons"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+
+	var candidates []Candidate
+	for _, i := range manifest.Icons {
+		ref, err := manifestURL.Parse(i.Src)
+		if err != nil || !fetchable(manifestURL, ref) {
+			continue
+		}
+		candidates = append(candidates, Candidate{URL: ref.String(), Size: parseSizes(i.Sizes)})
+	}
+	return candidates
+}
+
+// get downloads url and returns the response body; file URLs are read from disk
+func (f *Finder) get(rawURL string) ([]byte, error) {
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme == "file" {
+		return os.ReadFile(u.Path)
+	}
+
+	resp, err := f.Client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIconBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIconBytes {
+		return nil, errors.New("response too large")
+	}
+	return data, nil
+}
+
+// parseAttributes returns the lower-cased attributes of an HTML tag
+func parseAttributes(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range attributePattern.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(match[1])] = match[2] + match[3] + match[4]
+	}
+	return attrs
+}
+
+// parseSizes returns the largest edge declared in a sizes attribute
+func parseSizes(sizes string) int {
+	largest := 0
+	for _, match := range sizesPattern.FindAllStringSubmatch(sizes, -1) {
+		if size, err := strconv.Atoi(match[1]); err == nil && size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
+
+// contains reports whether values contains value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package favicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zk3151463/pake-go/pkg/icon"
+)
+
+func pngBytes(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// hugePNG returns a small PNG whose header declares a 40000x40000 image
+func hugePNG(t *testing.T) []byte {
+	data := pngBytes(t, 16)
+	binary.BigEndian.PutUint32(data[16:20], 40000)
+	binary.BigEndian.PutUint32(data[20:24], 40000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func newSite(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	files := map[string][]byte{
+		"/": []byte(`<html><head>
+			<link rel="shortcut icon" href="/favicon-32.png" sizes="32x32">
+			<link href='/apple.png' rel='apple-touch-icon'>
+			<link rel="manifest" href="/static/manifest.json">
+		</head></html>`),
+		"/static/manifest.json": []byte(`{"icons": [{"src": "icon-512.png", "sizes": "192x192 512x512"}]}`),
+		"/favicon-32.png":       pngBytes(t, 32),
+		"/apple.png":            pngBytes(t, 180),
+		"/static/icon-512.png":  pngBytes(t, 512),
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func TestCandidates(t *testing.T) {
+	site := newSite(t)
+	defer site.Close()
+
+	candidates, err := NewFinder(site.Client(), t.TempDir()).Candidates(site.URL + "/")
+	if err != nil {
+		t.Fatalf("Failed to discover icons: %v", err)
+	}
+
+	want := []Candidate{
+		{URL: site.URL + "/static/icon-512.png", Size: 512},
+		{URL: site.URL + "/apple.png", Size: 180},
+		{URL: site.URL + "/favicon-32.png", Size: 32},
+		{URL: site.URL + "/favicon.ico", Size: 16},
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("Expected %d candidates, got %v", len(want), candidates)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("Candidate %d = %v, want %v", i, candidates[i], want[i])
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	site := newSite(t)
+	finder := NewFinder(site.Client(), t.TempDir())
+
+	path, err := finder.Fetch(site.URL + "/")
+	if err != nil {
+		t.Fatalf("Failed to fetch icon: %v", err)
+	}
+
+	img, err := icon.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load cached icon: %v", err)
+	}
+	if img.Bounds().Dx() != 512 {
+		t.Errorf("Expected the 512px manifest icon, got %dpx", img.Bounds().Dx())
+	}
+
+	// Expire the cache and take the site offline
+	site.Close()
+	stale := time.Now().Add(-2 * CacheTTL)
+	if err := os.Chtimes(path, stale, stale); err != nil {
+		t.Fatalf("Failed to age cache: %v", err)
+	}
+
+	offline, err := finder.Fetch(site.URL + "/")
+	if err != nil {
+		t.Fatalf("Expected offline fallback to the cached icon, got %v", err)
+	}
+	if offline != path {
+		t.Errorf("Expected cached icon %s, got %s", path, offline)
+	}
+
+	if _, err := NewFinder(site.Client(), t.TempDir()).Fetch(site.URL + "/"); err == nil {
+		t.Error("Expected error when offline without a cached icon")
+	}
+}
+
+func TestFetchOffline(t *testing.T) {
+	finder := &Finder{Client: http.DefaultClient, CacheDir: t.TempDir(), Offline: true}
+
+	if _, err := finder.Fetch("https://example.invalid/app"); err == nil {
+		t.Fatal("Expected error without a cached icon")
+	}
+
+	cachePath := finder.cachePath("https://example.invalid/app")
+	if err := os.WriteFile(cachePath, []byte("icon"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * CacheTTL)
+	os.Chtimes(cachePath, old, old)
+
+	path, err := finder.Fetch("https://example.invalid/app")
+	if err != nil || path != cachePath {
+		t.Errorf("Expected stale cached icon while offline, got %q, %v", path, err)
+	}
+}
+
+func TestFetchUntrustedSite(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.png")
+	if err := os.WriteFile(secret, pngBytes(t, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A remote page linking a local file and serving malformed icons
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `<link rel="icon" href="file://%s" sizes="1024x1024"><link rel="icon" href="/ok.png">`, filepath.ToSlash(secret))
+			fmt.Fprint(w, `<link rel="apple-touch-icon" href="/huge.png" sizes="512x512">`)
+		case "/ok.png":
+			w.Write(pngBytes(t, 64))
+		case "/huge.png":
+			w.Write(hugePNG(t))
+		case "/favicon.ico":
+			ico := []byte{0, 0, 1, 0, 1, 0, 2, 2, 0, 0, 1, 0, 32, 0, 40, 0, 0, 0, 22, 0, 0, 0}
+			dib := make([]byte, 40)
+			binary.LittleEndian.PutUint32(dib[0:], 1000)
+			binary.LittleEndian.PutUint32(dib[4:], 2)
+			binary.LittleEndian.PutUint32(dib[8:], 4)
+			binary.LittleEndian.PutUint16(dib[14:], 32)
+			w.Write(append(ico, dib...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+
+	finder := NewFinder(site.Client(), t.TempDir())
+	candidates, err := finder.Candidates(site.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate.URL, "file:") {
+			t.Errorf("Expected no local file candidate for a remote page, got %s", candidate.URL)
+		}
+	}
+
+	path, err := finder.Fetch(site.URL + "/")
+	if err != nil {
+		t.Fatalf("Failed to fetch icon: %v", err)
+	}
+	if img, err := icon.Load(path); err != nil || img.Bounds().Dx() != 64 {
+		t.Errorf("Expected the 64px icon, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+// DefaultTimeout bounds every request made by pake-go itself
+const DefaultTimeout = 30 * time.Second
+
+// New returns an HTTP client that honours the proxy settings. A nil proxy or
+// one without URL falls back to the proxy environment variables; PAC files
+// are only evaluated by the webview, so they also fall back here.
+func New(proxy *config.ProxyConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = ProxyFunc(proxy)
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   DefaultTimeout,
+	}
+}
+
+// ProxyFunc returns the proxy selection function for the proxy settings
+func ProxyFunc(proxy *config.ProxyConfig) func(*http.Request) (*url.URL, error) {
+	if proxy == nil || proxy.URL == "" {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyURL, err := url.Parse(proxy.URL)
+	return func(req *http.Request) (*url.URL, error) {
+		if err != nil {
+			return nil, err
+		}
+		if Bypassed(req.URL.Hostname(), proxy.Bypass) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// Bypassed reports whether host matches one of the bypass entries. Entries
+// may be exact hosts, "*.example.com" or ".example.com" suffixes, CIDR
+// ranges, "*" for everything or "<local>" for hosts without a dot.
+func Bypassed(host string, bypass []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range bypass {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case entry == "<local>":
+			if !strings.Contains(host, ".") && ip == nil {
+				return true
+			}
+		case strings.HasPrefix(entry, "*."):
+			if strings.HasSuffix(host, entry[1:]) {
+				return true
+			}
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) || host == entry[1:] {
+				return true
+			}
+		case strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		default:
+			if host == entry {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+)
+
+func TestNewUsesProxy(t *testing.T) {
+	// A local proxy stand-in that answers every request itself
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	client := New(&config.ProxyConfig{
+		URL:    proxy.URL,
+		Bypass: []string{"*.internal.test"},
+	})
+
+	resp, err := client.Get("http://app.example.test/page")
+	if err != nil {
+		t.Fatalf("Failed to fetch through proxy: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "via proxy" {
+		t.Errorf("Expected response from proxy, got %q", body)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://app.example.test/page" {
+		t.Errorf("Expected proxied request for app.example.test, got %v", proxied)
+	}
+
+	req := &http.Request{URL: &url.URL{Scheme: "http", Host: "wiki.internal.test"}}
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil {
+		t.Fatalf("Failed to resolve proxy: %v", err)
+	}
+	if proxyURL != nil {
+		t.Errorf("Expected bypassed host to connect directly, got %v", proxyURL)
+	}
+}
+
+func TestBypassed(t *testing.T) {
+	bypass := []string{"localhost", "*.corp.example.com", ".lan", "10.0.0.0/8", "<local>"}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"localhost", true},
+		{"wiki.corp.example.com", true},
+		{"corp.example.com", false},
+		{"printer.lan", true},
+		{"lan", true},
+		{"10.1.2.3", true},
+		{"11.1.2.3", false},
+		{"intranet", true},
+		{"example.com", false},
+		{"notcorp.example.com.evil.net", false},
+	}
+
+	for _, tt := range tests {
+		if got := Bypassed(tt.host, bypass); got != tt.want {
+			t.Errorf("Bypassed(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
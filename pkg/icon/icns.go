@@ -0,0 +1,57 @@
+package icon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+)
+
+// icnsTypes maps ICNS element types to the PNG edge they contain
+var icnsTypes = []struct {
+	kind string
+	size int
+}{
+	{"icp4", 16},
+	{"icp5", 32},
+	{"ic11", 32}, // 16@2x
+	{"icp6", 64},
+	{"ic12", 64}, // 32@2x
+	{"ic07", 128},
+	{"ic08", 256},
+	{"ic13", 256}, // 128@2x
+	{"ic09", 512},
+	{"ic14", 512}, // 256@2x
+	{"ic10", 1024},
+}
+
+// EncodeICNS writes a macOS ICNS file with PNG compressed elements
+func EncodeICNS(w io.Writer, img image.Image) error {
+	encoded := make(map[int][]byte)
+	var body bytes.Buffer
+
+	for _, t := range icnsTypes {
+		data, ok := encoded[t.size]
+		if !ok {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, Resize(img, t.size)); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+			encoded[t.size] = data
+		}
+
+		body.WriteString(t.kind)
+		binary.Write(&body, binary.BigEndian, uint32(8+len(data)))
+		body.Write(data)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("icns")
+	binary.Write(&out, binary.BigEndian, uint32(8+body.Len()))
+	out.Write(body.Bytes())
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,160 @@
+package icon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// isICO reports whether data starts with an ICO header
+func isICO(data []byte) bool {
+	return len(data) >= 6 && binary.LittleEndian.Uint16(data[0:2]) == 0 &&
+		binary.LittleEndian.Uint16(data[2:4]) == 1 && binary.LittleEndian.Uint16(data[4:6]) > 0
+}
+
+// icoEntry is a directory entry of an ICO file
+type icoEntry struct {
+	width  int
+	height int
+	size   uint32
+	offset uint32
+}
+
+// decodeICO decodes the largest image of an ICO file. Entries may be PNG
+// compressed or 24/32-bit BMP bitmaps.
+func decodeICO(data []byte) (image.Image, error) {
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	if len(data) < 6+count*16 {
+		return nil, errors.New("truncated ICO directory")
+	}
+
+	var best *icoEntry
+	for i := 0; i < count; i++ {
+		raw := data[6+i*16 : 6+(i+1)*16]
+		entry := icoEntry{
+			width:  int(raw[0]),
+			height: int(raw[1]),
+			size:   binary.LittleEndian.Uint32(raw[8:12]),
+			offset: binary.LittleEndian.Uint32(raw[12:16]),
+		}
+		// A zero dimension means 256 pixels
+		if entry.width == 0 {
+			entry.width = 256
+		}
+		if entry.height == 0 {
+			entry.height = 256
+		}
+		if best == nil || entry.width > best.width {
+			best = &entry
+		}
+	}
+
+	end := uint64(best.offset) + uint64(best.size)
+	if end > uint64(len(data)) {
+		return nil, errors.New("truncated ICO image")
+	}
+	payload := data[best.offset:end]
+
+	if bytes.HasPrefix(payload, []byte("\x89PNG")) {
+		return decodeImage(payload)
+	}
+	return decodeDIB(payload)
+}
+
+// decodeDIB decodes an uncompressed 24 or 32-bit ICO bitmap with its AND mask
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errors.New("truncated ICO bitmap")
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	// The height covers both the XOR bitmap and the AND mask
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+
+	if compression != 0 || (bpp != 24 && bpp != 32) {
+		return nil, fmt.Errorf("unsupported ICO bitmap: %d bpp, compression %d", bpp, compression)
+	}
+	if width <= 0 || height <= 0 || width > maxSize || height > maxSize {
+		return nil, errors.New("invalid ICO bitmap size")
+	}
+	if headerSize < 40 || headerSize > len(data) {
+		return nil, fmt.Errorf("invalid ICO bitmap header size %d", headerSize)
+	}
+
+	stride := (width*bpp/8 + 3) &^ 3
+	maskStride := ((width+7)/8 + 3) &^ 3
+	pixels := data[headerSize:]
+	if len(pixels) < stride*height {
+		return nil, errors.New("truncated ICO bitmap")
+	}
+	// The AND mask is optional for 32-bit bitmaps, which carry alpha
+	mask := pixels[stride*height:]
+	if bpp == 24 && len(mask) < maskStride*height {
+		return nil, errors.New("truncated ICO bitmap mask")
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		// Rows are stored bottom-up
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			px := row[x*bpp/8:]
+			c := color.NRGBA{R: px[2], G: px[1], B: px[0], A: 255}
+			if bpp == 32 {
+				c.A = px[3]
+			} else {
+				bit := mask[(height-1-y)*maskStride+x/8] & (0x80 >> uint(x%8))
+				if bit != 0 {
+					c.A = 0
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img, nil
+}
+
+// EncodeICO writes a multi-resolution ICO file with PNG compressed entries
+func EncodeICO(w io.Writer, img image.Image, sizes []int) error {
+	images := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, Resize(img, size)); err != nil {
+			return err
+		}
+		images[i] = buf.Bytes()
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, [3]uint16{0, 1, uint16(len(sizes))})
+
+	offset := 6 + 16*len(sizes)
+	for i, size := range sizes {
+		dim := byte(size)
+		if size >= 256 {
+			dim = 0
+		}
+		out.Write([]byte{dim, dim, 0, 0})
+		binary.Write(&out, binary.LittleEndian, uint16(1))  // colour planes
+		binary.Write(&out, binary.LittleEndian, uint16(32)) // bits per pixel
+		binary.Write(&out, binary.LittleEndian, uint32(len(images[i])))
+		binary.Write(&out, binary.LittleEndian, uint32(offset))
+		offset += len(images[i])
+	}
+
+	for _, data := range images {
+		out.Write(data)
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,310 @@
+package icon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// MinSize is the smallest accepted source icon edge in pixels
+const MinSize = 16
+
+// AppIconSize is the edge of the appicon.png Wails reads from the build dir
+const AppIconSize = 1024
+
+// ICOSizes are the resolutions embedded in the Windows icon
+var ICOSizes = []int{16, 24, 32, 48, 64, 128, 256}
+
+// HicolorSizes are the resolutions installed into the Linux hicolor theme
+var HicolorSizes = []int{16, 22, 24, 32, 48, 64, 128, 256, 512}
+
+// maxSize bounds the edges of decoded images. Image headers are checked
+// before decoding, as decoders allocate the declared pixel buffer up front.
+const maxSize = 4096
+
+var svgImagePattern = regexp.MustCompile(`(?:xlink:)?href\s*=\s*["']data:image/(?:png|jpeg|jpg);base64,([A-Za-z0-9+/=\s]+)["']`)
+
+// Load reads and decodes an icon file
+func Load(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Decode decodes PNG, JPEG, ICO or SVG data. SVG files are accepted when they
+// wrap an already rasterized PNG or JPEG image, as exported by most design tools.
+func Decode(data []byte) (image.Image, error) {
+	var img image.Image
+	var err error
+
+	switch {
+	case isICO(data):
+		img, err = decodeICO(data)
+	case isSVG(data):
+		img, err = decodeSVG(data)
+	default:
+		img, err = decodeImage(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() < MinSize || bounds.Dy() < MinSize {
+		return nil, fmt.Errorf("icon is %dx%d, at least %dx%d is required", bounds.Dx(), bounds.Dy(), MinSize, MinSize)
+	}
+
+	return img, nil
+}
+
+// decodeImage decodes PNG or JPEG data after checking the declared size
+func decodeImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width > maxSize || cfg.Height > maxSize {
+		return nil, fmt.Errorf("image is %dx%d, at most %dx%d is supported", cfg.Width, cfg.Height, maxSize, maxSize)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// isSVG reports whether data looks like an SVG document
+func isSVG(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(head, []byte("<svg")) || bytes.Contains(head, []byte("<?xml"))
+}
+
+// decodeSVG extracts the largest raster image embedded in an SVG document
+func decodeSVG(data []byte) (image.Image, error) {
+	var best image.Image
+	for _, match := range svgImagePattern.FindAllSubmatch(data, -1) {
+		raw, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(match[1]), nil)))
+		if err != nil {
+			continue
+		}
+		img, err := decodeImage(raw)
+		if err != nil {
+			continue
+		}
+		if best == nil || img.Bounds().Dx() > best.Bounds().Dx() {
+			best = img
+		}
+	}
+
+	if best == nil {
+		return nil, errors.New("SVG icons must embed a rasterized PNG or JPEG image; export the icon as PNG instead")
+	}
+	return best, nil
+}
+
+// Square pads img with transparency so that it becomes square
+func Square(img image.Image) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() == bounds.Dy() {
+		return img
+	}
+
+	size := bounds.Dx()
+	if bounds.Dy() > size {
+		size = bounds.Dy()
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	offset := image.Pt((size-bounds.Dx())/2, (size-bounds.Dy())/2)
+	draw.Draw(dst, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min, draw.Src)
+	return dst
+}
+
+// Resize scales img to a size x size square. Downscaling averages every
+// source pixel covered by a target pixel; upscaling interpolates bilinearly.
+func Resize(img image.Image, size int) *image.NRGBA {
+	src := image.NewNRGBA(img.Bounds().Sub(img.Bounds().Min))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	if src.Bounds().Dx() == size && src.Bounds().Dy() == size {
+		return src
+	}
+	if src.Bounds().Dx() > size && src.Bounds().Dy() > size {
+		return resizeArea(src, size)
+	}
+	return resizeBilinear(src, size)
+}
+
+// resizeArea downscales using a box filter in premultiplied alpha
+func resizeArea(src *image.NRGBA, size int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < size; y++ {
+		y0, y1 := y*sh/size, (y+1)*sh/size
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size; x++ {
+			x0, x1 := x*sw/size, (x+1)*sw/size
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.NRGBAAt(sx, sy)
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					b += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+
+			if a > 0 {
+				dst.SetNRGBA(x, y, color.NRGBA{
+					R: uint8(r / a),
+					G: uint8(g / a),
+					B: uint8(b / a),
+					A: uint8(a / n),
+				})
+			}
+		}
+	}
+
+	return dst
+}
+
+// resizeBilinear upscales using bilinear interpolation in premultiplied alpha
+func resizeBilinear(src *image.NRGBA, size int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < size; y++ {
+		fy := (float64(y)+0.5)*float64(sh)/float64(size) - 0.5
+		for x := 0; x < size; x++ {
+			fx := (float64(x)+0.5)*float64(sw)/float64(size) - 0.5
+			dst.SetNRGBA(x, y, sampleBilinear(src, fx, fy))
+		}
+	}
+
+	return dst
+}
+
+// sampleBilinear returns the interpolated colour at fractional coordinates
+func sampleBilinear(src *image.NRGBA, fx, fy float64) color.NRGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	clamp := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= max {
+			return max - 1
+		}
+		return v
+	}
+
+	x0 := int(fx)
+	if fx < 0 {
+		x0 = -1
+	}
+	y0 := int(fy)
+	if fy < 0 {
+		y0 = -1
+	}
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	var r, g, b, a float64
+	for _, p := range []struct {
+		x, y int
+		w    float64
+	}{
+		{x0, y0, (1 - tx) * (1 - ty)},
+		{x0 + 1, y0, tx * (1 - ty)},
+		{x0, y0 + 1, (1 - tx) * ty},
+		{x0 + 1, y0 + 1, tx * ty},
+	} {
+		c := src.NRGBAAt(clamp(p.x, sw), clamp(p.y, sh))
+		alpha := float64(c.A) * p.w
+		r += float64(c.R) * alpha
+		g += float64(c.G) * alpha
+		b += float64(c.B) * alpha
+		a += alpha
+	}
+
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: uint8(r/a + 0.5),
+		G: uint8(g/a + 0.5),
+		B: uint8(b/a + 0.5),
+		A: uint8(a + 0.5),
+	}
+}
+
+// Generate writes every platform icon format for img into the Wails build
+// directory: appicon.png, windows/icon.ico, darwin/iconfile.icns and the
+// Linux hicolor theme PNGs named after the application.
+func Generate(img image.Image, buildDir string, name string) error {
+	img = Square(img)
+
+	if err := writePNG(filepath.Join(buildDir, "appicon.png"), Resize(img, AppIconSize)); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(buildDir, "windows", "icon.ico"), func(buf *bytes.Buffer) error {
+		return EncodeICO(buf, img, ICOSizes)
+	}); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(buildDir, "darwin", "iconfile.icns"), func(buf *bytes.Buffer) error {
+		return EncodeICNS(buf, img)
+	}); err != nil {
+		return err
+	}
+
+	for _, size := range HicolorSizes {
+		path := filepath.Join(buildDir, "linux", "hicolor", fmt.Sprintf("%dx%d", size, size), "apps", name+".png")
+		if err := writePNG(path, Resize(img, size)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writePNG encodes img as PNG into path
+func writePNG(path string, img image.Image) error {
+	return writeFile(path, func(buf *bytes.Buffer) error {
+		return png.Encode(buf, img)
+	})
+}
+
+// writeFile writes the encoded output to path, creating parent directories
+func writeFile(path string, encode func(*bytes.Buffer) error) error {
+	var buf bytes.Buffer
+	if err := encode(&buf); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
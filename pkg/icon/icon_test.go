@@ -0,0 +1,217 @@
+package icon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testImage returns a size x size image with an opaque red square in the middle
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := height / 4; y < height*3/4; y++ {
+		for x := width / 4; x < width*3/4; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerate(t *testing.T) {
+	buildDir := t.TempDir()
+	img, err := Decode(encodePNG(t, testImage(512, 512)))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+
+	if err := Generate(img, buildDir, "TestApp"); err != nil {
+		t.Fatalf("Failed to generate icons: %v", err)
+	}
+
+	appIcon, err := Load(filepath.Join(buildDir, "appicon.png"))
+	if err != nil {
+		t.Fatalf("Failed to load appicon.png: %v", err)
+	}
+	if appIcon.Bounds().Dx() != AppIconSize || appIcon.Bounds().Dy() != AppIconSize {
+		t.Errorf("Expected %dpx appicon.png, got %v", AppIconSize, appIcon.Bounds())
+	}
+	if _, _, _, a := appIcon.At(AppIconSize/2, AppIconSize/2).RGBA(); a == 0 {
+		t.Errorf("Expected opaque centre pixel in appicon.png")
+	}
+
+	ico, err := os.ReadFile(filepath.Join(buildDir, "windows", "icon.ico"))
+	if err != nil {
+		t.Fatalf("Failed to read icon.ico: %v", err)
+	}
+	if count := binary.LittleEndian.Uint16(ico[4:6]); int(count) != len(ICOSizes) {
+		t.Errorf("Expected %d ICO entries, got %d", len(ICOSizes), count)
+	}
+	largest, err := Decode(ico)
+	if err != nil {
+		t.Fatalf("Failed to decode generated ICO: %v", err)
+	}
+	if largest.Bounds().Dx() != 256 {
+		t.Errorf("Expected largest ICO entry to be 256px, got %d", largest.Bounds().Dx())
+	}
+
+	icns, err := os.ReadFile(filepath.Join(buildDir, "darwin", "iconfile.icns"))
+	if err != nil {
+		t.Fatalf("Failed to read iconfile.icns: %v", err)
+	}
+	if string(icns[:4]) != "icns" || int(binary.BigEndian.Uint32(icns[4:8])) != len(icns) {
+		t.Errorf("Invalid ICNS header")
+	}
+
+	for _, size := range HicolorSizes {
+		path := filepath.Join(buildDir, "linux", "hicolor", fmt.Sprintf("%dx%d", size, size), "apps", "TestApp.png")
+		hicolor, err := Load(path)
+		if err != nil {
+			t.Errorf("Failed to load hicolor icon %d: %v", size, err)
+			continue
+		}
+		if hicolor.Bounds().Dx() != size {
+			t.Errorf("Expected %dpx hicolor icon, got %d", size, hicolor.Bounds().Dx())
+		}
+	}
+}
+
+func TestDecodeFormats(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, testImage(64, 64), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	if img, err := Decode(jpg.Bytes()); err != nil || img.Bounds().Dx() != 64 {
+		t.Errorf("Failed to decode JPEG: %v", err)
+	}
+
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64"><image href="data:image/png;base64,` +
+		base64.StdEncoding.EncodeToString(encodePNG(t, testImage(64, 64))) + `"/></svg>`
+	if img, err := Decode([]byte(svg)); err != nil || img.Bounds().Dx() != 64 {
+		t.Errorf("Failed to decode SVG with embedded PNG: %v", err)
+	}
+
+	if _, err := Decode([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><circle r="4"/></svg>`)); err == nil {
+		t.Error("Expected error for vector-only SVG")
+	}
+
+	if _, err := Decode(encodePNG(t, testImage(8, 8))); err == nil {
+		t.Error("Expected error for icon smaller than the minimum size")
+	}
+}
+
+// hugePNG returns a small PNG whose header declares a size x size image
+func hugePNG(t *testing.T, size uint32) []byte {
+	data := encodePNG(t, testImage(16, 16))
+	binary.BigEndian.PutUint32(data[16:20], size)
+	binary.BigEndian.PutUint32(data[20:24], size)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestDecodeHugeImage(t *testing.T) {
+	data := hugePNG(t, 40000)
+
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, 1})
+	ico.Write([]byte{0, 0, 0, 0})
+	binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})
+	binary.Write(&ico, binary.LittleEndian, []uint32{uint32(len(data)), 22})
+	ico.Write(data)
+
+	svg := `<svg><image href="data:image/png;base64,` + base64.StdEncoding.EncodeToString(data) + `"/></svg>`
+
+	for name, data := range map[string][]byte{"PNG": data, "ICO": ico.Bytes(), "SVG": []byte(svg)} {
+		if _, err := Decode(data); err == nil {
+			t.Errorf("Expected an error for a %s declaring 40000x40000 pixels", name)
+		}
+	}
+}
+
+func TestDecodeBitmapICO(t *testing.T) {
+	// A 2x2 32-bit bitmap entry: bottom row blue, top row green
+	const size = 2
+	var dib bytes.Buffer
+	binary.Write(&dib, binary.LittleEndian, []uint32{40, size, size * 2})
+	binary.Write(&dib, binary.LittleEndian, []uint16{1, 32})
+	binary.Write(&dib, binary.LittleEndian, make([]uint32, 6))
+	dib.Write([]byte{255, 0, 0, 255, 255, 0, 0, 255})
+	dib.Write([]byte{0, 255, 0, 255, 0, 255, 0, 255})
+	dib.Write(make([]byte, 8)) // AND mask
+
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, 1})
+	ico.Write([]byte{size, size, 0, 0})
+	binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})
+	binary.Write(&ico, binary.LittleEndian, []uint32{uint32(dib.Len()), 22})
+	ico.Write(dib.Bytes())
+
+	img, err := decodeICO(ico.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode bitmap ICO: %v", err)
+	}
+	if c := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA); c.G != 255 || c.B != 0 {
+		t.Errorf("Expected green top row, got %v", c)
+	}
+	if c := color.NRGBAModel.Convert(img.At(0, 1)).(color.NRGBA); c.B != 255 || c.G != 0 {
+		t.Errorf("Expected blue bottom row, got %v", c)
+	}
+}
+
+func TestDecodeMalformedICO(t *testing.T) {
+	// dib returns a bitmap entry with the given header fields and payload
+	dib := func(headerSize, width, height uint32, bpp uint16, payload int) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, []uint32{headerSize, width, height})
+		binary.Write(&buf, binary.LittleEndian, []uint16{1, bpp})
+		binary.Write(&buf, binary.LittleEndian, make([]uint32, 6))
+		buf.Write(make([]byte, payload))
+		return buf.Bytes()
+	}
+	tests := map[string][]byte{
+		"header size past the end": dib(1000, 2, 4, 32, 0),
+		"header size too small":    dib(4, 2, 4, 32, 16),
+		"truncated pixels":         dib(40, 2, 4, 32, 8),
+		"truncated mask":           dib(40, 2, 4, 24, 16),
+		"huge bitmap":              dib(40, 1<<30, 1<<30, 32, 0),
+	}
+	for name, entry := range tests {
+		var ico bytes.Buffer
+		binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, 1})
+		ico.Write([]byte{2, 2, 0, 0})
+		binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})
+		binary.Write(&ico, binary.LittleEndian, []uint32{uint32(len(entry)), 22})
+		ico.Write(entry)
+
+		if _, err := Decode(ico.Bytes()); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestSquare(t *testing.T) {
+	img := Square(testImage(100, 50))
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 100 {
+		t.Errorf("Expected 100x100 padded image, got %v", img.Bounds())
+	}
+	if _, _, _, a := img.At(50, 5).RGBA(); a != 0 {
+		t.Errorf("Expected transparent padding")
+	}
+}
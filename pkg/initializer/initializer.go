@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os/exec"
 	"runtime"
+
+	"github.com/zk3151463/pake-go/pkg/builder"
 )
 
 // InitEnvironment initializes the development environment
@@ -67,7 +69,7 @@ func checkAndInstallWails() error {
 	}
 
 	fmt.Println("Installing Wails...")
-	cmd := exec.Command("go", "install", "github.com/wailsapp/wails/v2/cmd/wails@latest")
+	cmd := exec.Command("go", "install", "github.com/wailsapp/wails/v2/cmd/wails@"+builder.WailsVersion)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to install Wails: %v\n%s", err, output)
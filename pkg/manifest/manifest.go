@@ -0,0 +1,182 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/favicon"
+)
+
+var (
+	hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	sizesPattern    = regexp.MustCompile(`(\d+)[xX](\d+)`)
+)
+
+// Manifest is the subset of a Web App Manifest used to configure an app
+type Manifest struct {
+	Name            string `json:"name"`
+	ShortName       string `json:"short_name"`
+	StartURL        string `json:"start_url"`
+	Display         string `json:"display"`
+	BackgroundColor string `json:"background_color"`
+	Icons           []Icon `json:"icons"`
+
+	// base is the location the manifest was loaded from, used to resolve
+	// relative start_url and icon sources
+	base *url.URL
+}
+
+// Icon is an icon listed in a manifest
+type Icon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose"`
+}
+
+// Load reads a manifest from an http(s) URL or a local file
+func Load(source string, client *http.Client) (*Manifest, error) {
+	var data []byte
+	var base *url.URL
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", source, resp.Status)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return nil, err
+		}
+		base = u
+	} else {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = os.ReadFile(abs); err != nil {
+			return nil, err
+		}
+		base = &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	}
+
+	return Parse(data, base)
+}
+
+// Parse decodes manifest data loaded from base
+func Parse(data []byte, base *url.URL) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	m.base = base
+	return m, nil
+}
+
+// ResolvedStartURL returns start_url as an absolute http(s) URL, or an empty
+// string when it cannot be resolved from a local manifest
+func (m *Manifest) ResolvedStartURL() string {
+	if m.StartURL == "" {
+		if m.base != nil && m.base.Scheme != "file" {
+			return m.base.ResolveReference(&url.URL{Path: "/"}).String()
+		}
+		return ""
+	}
+
+	ref, err := url.Parse(m.StartURL)
+	if err != nil {
+		return ""
+	}
+	if ref.IsAbs() {
+		return ref.String()
+	}
+	if m.base == nil || m.base.Scheme == "file" {
+		return ""
+	}
+	return m.base.ResolveReference(ref).String()
+}
+
+// IconCandidates returns the manifest icons as absolute URLs, resolving
+// sources against the manifest location
+func (m *Manifest) IconCandidates() []favicon.Candidate {
+	var candidates []favicon.Candidate
+	for _, i := range m.Icons {
+		// Monochrome icons are silhouettes meant for masking
+		if strings.Contains(i.Purpose, "monochrome") && !strings.Contains(i.Purpose, "any") {
+			continue
+		}
+
+		ref, err := url.Parse(i.Src)
+		if err != nil || m.base == nil {
+			continue
+		}
+		// Only a local manifest may point at local files
+		src := m.base.ResolveReference(ref)
+		if src.Scheme == "file" && m.base.Scheme != "file" {
+			continue
+		}
+		candidates = append(candidates, favicon.Candidate{
+			URL:  src.String(),
+			Size: largestSize(i.Sizes),
+		})
+	}
+	return candidates
+}
+
+// Apply copies the manifest settings into cfg. The largest icon is
+// downloaded with finder; a failed download leaves the icon unset.
+func (m *Manifest) Apply(cfg *config.Config, finder *favicon.Finder) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	} else if m.ShortName != "" {
+		cfg.Name = m.ShortName
+	}
+
+	if startURL := m.ResolvedStartURL(); startURL != "" {
+		cfg.URL = startURL
+	}
+
+	if hexColorPattern.MatchString(m.BackgroundColor) {
+		cfg.BackgroundColor = m.BackgroundColor
+	}
+
+	switch m.Display {
+	case "standalone", "fullscreen":
+		cfg.HideTitleBar = true
+	}
+
+	if candidates := m.IconCandidates(); len(candidates) > 0 && finder != nil {
+		if path, err := finder.FetchCandidates(candidates[0].URL, candidates); err == nil {
+			cfg.Icon = path
+		}
+	}
+}
+
+// largestSize returns the largest edge declared in a sizes attribute
+func largestSize(sizes string) int {
+	largest := 0
+	for _, match := range sizesPattern.FindAllStringSubmatch(sizes, -1) {
+		if size, err := strconv.Atoi(match[1]); err == nil && size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
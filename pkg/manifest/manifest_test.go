@@ -0,0 +1,118 @@
+package manifest
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/favicon"
+	"github.com/zk3151463/pake-go/pkg/icon"
+)
+
+const testManifest = `{
+	"name": "Team Wiki",
+	"short_name": "Wiki",
+	"start_url": "/app/?source=pwa",
+	"display": "standalone",
+	"background_color": "#202124",
+	"theme_color": "#1a73e8",
+	"icons": [
+		{"src": "icons/192.png", "sizes": "192x192", "type": "image/png"},
+		{"src": "icons/512.png", "sizes": "512x512", "type": "image/png"},
+		{"src": "icons/mono.png", "sizes": "1024x1024", "purpose": "monochrome"}
+	]
+}`
+
+func pngBytes(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadFromURL(t *testing.T) {
+	files := map[string][]byte{
+		"/static/manifest.webmanifest": []byte(testManifest),
+		"/static/icons/192.png":        pngBytes(t, 192),
+		"/static/icons/512.png":        pngBytes(t, 512),
+	}
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if data, ok := files[r.URL.Path]; ok {
+			w.Write(data)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer site.Close()
+
+	m, err := Load(site.URL+"/static/manifest.webmanifest", site.Client())
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	m.Apply(cfg, favicon.NewFinder(site.Client(), t.TempDir()))
+
+	if cfg.Name != "Team Wiki" {
+		t.Errorf("Expected name from manifest, got %q", cfg.Name)
+	}
+	if cfg.URL != site.URL+"/app/?source=pwa" {
+		t.Errorf("Expected resolved start_url, got %q", cfg.URL)
+	}
+	if cfg.BackgroundColor != "#202124" {
+		t.Errorf("Expected background color from manifest, got %q", cfg.BackgroundColor)
+	}
+	if !cfg.HideTitleBar {
+		t.Error("Expected standalone display to hide the title bar")
+	}
+
+	img, err := icon.Load(cfg.Icon)
+	if err != nil {
+		t.Fatalf("Failed to load downloaded icon: %v", err)
+	}
+	if img.Bounds().Dx() != 512 {
+		t.Errorf("Expected the 512px icon, got %dpx", img.Bounds().Dx())
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "icons"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "icons", "512.png"), pngBytes(t, 512), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(path, []byte(testManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(path, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	if url := m.ResolvedStartURL(); url != "" {
+		t.Errorf("Expected relative start_url to stay unresolved for a local manifest, got %q", url)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.URL = "https://wiki.example.com/"
+	m.Apply(cfg, favicon.NewFinder(http.DefaultClient, t.TempDir()))
+
+	if cfg.URL != "https://wiki.example.com/" {
+		t.Errorf("Expected URL to be kept, got %q", cfg.URL)
+	}
+	if cfg.Icon == "" {
+		t.Error("Expected local manifest icon to be used")
+	}
+}
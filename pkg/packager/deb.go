@@ -0,0 +1,204 @@
+package packager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultDebDepends are the runtime libraries a Wails application needs on Debian and Ubuntu
+var DefaultDebDepends = []string{"libgtk-3-0", "libwebkit2gtk-4.0-37"}
+
+// DebDependsWebKit41 are the runtime libraries of an application built with
+// the webkit2_41 tag
+var DebDependsWebKit41 = []string{"libgtk-3-0", "libwebkit2gtk-4.1-0"}
+
+// debArch maps Go architectures to Debian ones
+var debArch = map[string]string{
+	"amd64": "amd64",
+	"arm64": "arm64",
+	"386":   "i386",
+	"arm":   "armhf",
+}
+
+// BuildDeb writes a .deb package into outDir and returns its path
+func BuildDeb(i *Info, outDir string) (string, error) {
+	files, err := i.files()
+	if err != nil {
+		return "", err
+	}
+
+	arch, ok := debArch[i.Arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported deb architecture %q", i.Arch)
+	}
+
+	data, err := tarGz(i.ModTime, func(tw *tar.Writer) error {
+		return writeTarTree(tw, ".", files, i.ModTime)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var md5sums strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&md5sums, "%x  %s\n", md5.Sum(f.data), strings.TrimPrefix(f.path, "/"))
+	}
+
+	control, err := tarGz(i.ModTime, func(tw *tar.Writer) error {
+		if err := writeTarFile(tw, "./control", 0644, []byte(debControl(i, arch, files)), i.ModTime); err != nil {
+			return err
+		}
+		return writeTarFile(tw, "./md5sums", 0644, []byte(md5sums.String()), i.ModTime)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var deb bytes.Buffer
+	deb.WriteString("!<arch>\n")
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+		{"data.tar.gz", data},
+	} {
+		writeArMember(&deb, member.name, member.data, i.ModTime)
+	}
+
+	out := filepath.Join(outDir, fmt.Sprintf("%s_%s_%s.deb", i.Name, i.version(), arch))
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	return out, writeFile(out, deb.Bytes())
+}
+
+// debControl returns the control file of the package
+func debControl(i *Info, arch string, files []file) string {
+	depends := i.Depends
+	if len(depends) == 0 {
+		depends = DefaultDebDepends
+		if i.WebKit41 {
+			depends = DebDependsWebKit41
+		}
+	}
+
+	maintainer := i.Maintainer
+	if maintainer == "" {
+		maintainer = "Unknown <unknown@localhost>"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Package: %s\n", i.Name)
+	fmt.Fprintf(&b, "Version: %s\n", i.version())
+	fmt.Fprintf(&b, "Architecture: %s\n", arch)
+	fmt.Fprintf(&b, "Maintainer: %s\n", maintainer)
+	fmt.Fprintf(&b, "Installed-Size: %d\n", (installedSize(files)+1023)/1024)
+	fmt.Fprintf(&b, "Depends: %s\n", strings.Join(depends, ", "))
+	b.WriteString("Section: web\n")
+	b.WriteString("Priority: optional\n")
+	if i.Homepage != "" {
+		fmt.Fprintf(&b, "Homepage: %s\n", i.Homepage)
+	}
+	fmt.Fprintf(&b, "Description: %s\n", i.summary())
+
+	// Extended description lines are indented, with "." for empty lines
+	if parts := strings.SplitN(i.Description, "\n", 2); len(parts) == 2 {
+		for _, line := range strings.Split(strings.TrimSpace(parts[1]), "\n") {
+			if strings.TrimSpace(line) == "" {
+				line = "."
+			}
+			fmt.Fprintf(&b, " %s\n", line)
+		}
+	}
+
+	return b.String()
+}
+
+// writeArMember appends a file to an ar archive
+func writeArMember(buf *bytes.Buffer, name string, data []byte, modTime time.Time) {
+	fmt.Fprintf(buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", name, modTime.Unix(), 0, 0, 0100644, len(data))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte('\n')
+	}
+}
+
+// tarGz builds a gzip compressed tar archive
+func tarGz(modTime time.Time, write func(*tar.Writer) error) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.ModTime = modTime
+	tw := tar.NewWriter(gz)
+
+	if err := write(tw); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTarTree writes files below prefix, preceded by their parent directories
+func writeTarTree(tw *tar.Writer, prefix string, files []file, modTime time.Time) error {
+	written := make(map[string]bool)
+	for _, f := range files {
+		var dirs []string
+		for dir := path.Dir(f.path); dir != "/" && !written[dir]; dir = path.Dir(dir) {
+			dirs = append(dirs, dir)
+			written[dir] = true
+		}
+
+		for d := len(dirs) - 1; d >= 0; d-- {
+			hdr := &tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     prefix + dirs[d] + "/",
+				Mode:     0755,
+				ModTime:  modTime,
+				Uname:    "root",
+				Gname:    "root",
+				Format:   tar.FormatGNU,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+		}
+
+		if err := writeTarFile(tw, prefix+f.path, f.mode, f.data, modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTarFile writes a regular file
+func writeTarFile(tw *tar.Writer, name string, mode os.FileMode, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode),
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Uname:    "root",
+		Gname:    "root",
+		Format:   tar.FormatGNU,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,231 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	invalidNameChars    = regexp.MustCompile(`[^a-z0-9.+-]+`)
+	invalidVersionChars = regexp.MustCompile(`[^A-Za-z0-9.+~]+`)
+	// prereleaseVersion matches the semver prerelease or the -dirty suffix
+	// in its third group, after the commit count and hash git describe adds
+	// past a tag
+	prereleaseVersion = regexp.MustCompile(`^(\d+(?:\.\d+)*)(-\d+-g[0-9a-f]+)?(?:-([^+]+))?`)
+	sizeDirPattern    = regexp.MustCompile(`^\d+x\d+$`)
+)
+
+// Info describes the application being packaged
+type Info struct {
+	// Name is the package name, see PackageName
+	Name string
+	// AppName is the display name used in the desktop entry
+	AppName     string
+	Version     string
+	Arch        string
+	Description string
+	Maintainer  string
+	Homepage    string
+	License     string
+	Categories  []string
+	// Depends overrides the default dependencies
+	Depends []string
+	// WebKit41 is set for binaries built with the webkit2_41 tag, which link
+	// WebKitGTK 4.1 instead of 4.0
+	WebKit41 bool
+	// Binary is the path of the built application
+	Binary string
+	// IconDir is the hicolor theme directory produced by the icon pipeline
+	IconDir string
+	// ModTime is the timestamp recorded for every packaged file
+	ModTime time.Time
+}
+
+// file is a file installed by a package
+type file struct {
+	path string
+	mode os.FileMode
+	data []byte
+}
+
+// PackageName converts an application name into a valid package name
+func PackageName(name string) string {
+	pkg := invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	pkg = strings.Trim(pkg, "-.+")
+	if pkg == "" {
+		return "pake-app"
+	}
+	return pkg
+}
+
+// version returns the package version without a leading "v", made valid
+// for deb and rpm: a prerelease such as 1.2.3-rc1 becomes 1.2.3~rc1, which
+// sorts before 1.2.3, versions that do not start with a digit get a 0.0.0
+// prefix, and characters other than letters, digits, ".", "+" and "~"
+// become "."
+func (i *Info) version() string {
+	v := strings.TrimPrefix(i.Version, "v")
+	if m := prereleaseVersion.FindStringSubmatchIndex(v); m != nil && m[6] >= 0 {
+		v = v[:m[6]-1] + "~" + v[m[6]:]
+	}
+	v = strings.Trim(invalidVersionChars.ReplaceAllString(v, "."), ".")
+	if v == "" {
+		return "1.0.0"
+	}
+	if v[0] < '0' || v[0] > '9' {
+		v = "0.0.0." + v
+	}
+	return v
+}
+
+// summary returns the one line description of the package
+func (i *Info) summary() string {
+	if i.Description != "" {
+		return strings.SplitN(i.Description, "\n", 2)[0]
+	}
+	return i.AppName + " desktop application"
+}
+
+// Desktop returns the freedesktop.org desktop entry of the application
+func Desktop(i *Info) string {
+	categories := i.Categories
+	if len(categories) == 0 {
+		categories = []string{"Network"}
+	}
+
+	var b strings.Builder
+	b.WriteString("[Desktop Entry]\n")
+	b.WriteString("Type=Application\n")
+	b.WriteString("Name=" + i.AppName + "\n")
+	b.WriteString("Comment=" + i.summary() + "\n")
+	b.WriteString("Exec=" + i.Name + "\n")
+	b.WriteString("Icon=" + i.Name + "\n")
+	b.WriteString("Terminal=false\n")
+	b.WriteString("Categories=" + strings.Join(categories, ";") + ";\n")
+	// Wails sets the GTK program name, and thus the WM class, to the app name
+	b.WriteString("StartupWMClass=" + i.AppName + "\n")
+	return b.String()
+}
+
+// files returns every file installed by the packages, sorted by path
+func (i *Info) files() ([]file, error) {
+	binary, err := os.ReadFile(i.Binary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary: %w", err)
+	}
+
+	files := []file{
+		{path: "/usr/bin/" + i.Name, mode: 0755, data: binary},
+		{path: "/usr/share/applications/" + i.Name + ".desktop", mode: 0644, data: []byte(Desktop(i))},
+	}
+
+	icons, err := i.icons()
+	if err != nil {
+		return nil, err
+	}
+	for size, path := range icons {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{
+			path: "/usr/share/icons/hicolor/" + size + "/apps/" + i.Name + ".png",
+			mode: 0644,
+			data: data,
+		})
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].path < files[b].path
+	})
+	return files, nil
+}
+
+// icons returns the hicolor PNG of every size, keyed by size directory
+func (i *Info) icons() (map[string]string, error) {
+	icons := make(map[string]string)
+	if i.IconDir == "" {
+		return icons, nil
+	}
+
+	sizes, err := os.ReadDir(i.IconDir)
+	if os.IsNotExist(err) {
+		return icons, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, size := range sizes {
+		if !size.IsDir() || !sizeDirPattern.MatchString(size.Name()) {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(i.IconDir, size.Name(), "apps", "*.png"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			icons[size.Name()] = matches[0]
+		}
+	}
+
+	return icons, nil
+}
+
+// largestIcon returns the path of the largest hicolor icon, if any
+func (i *Info) largestIcon() (string, error) {
+	icons, err := i.icons()
+	if err != nil {
+		return "", err
+	}
+
+	best, bestSize := "", 0
+	for size, path := range icons {
+		var edge int
+		fmt.Sscanf(size, "%dx", &edge)
+		if edge > bestSize {
+			best, bestSize = path, edge
+		}
+	}
+	return best, nil
+}
+
+// installedSize returns the total size of files in bytes
+func installedSize(files []file) int64 {
+	var size int64
+	for _, f := range files {
+		size += int64(len(f.data))
+	}
+	return size
+}
+
+// writeFile writes data to a temp file next to path and renames it into
+// place, so an existing package is only replaced by a complete one
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
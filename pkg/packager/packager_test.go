@@ -0,0 +1,286 @@
+package packager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testInfo returns package info for a fake binary and icon set
+func testInfo(t *testing.T) *Info {
+	t.Helper()
+	dir := t.TempDir()
+
+	binary := filepath.Join(dir, "TestApp")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho test\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	iconDir := filepath.Join(dir, "hicolor")
+	for _, size := range []string{"32x32", "256x256"} {
+		apps := filepath.Join(iconDir, size, "apps")
+		if err := os.MkdirAll(apps, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(apps, "TestApp.png"), []byte("png "+size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &Info{
+		Name:        PackageName("Test App"),
+		AppName:     "Test App",
+		Version:     "v1.2.3",
+		Arch:        "amd64",
+		Description: "Test application\nWraps the test site.",
+		Maintainer:  "Test <test@example.com>",
+		Categories:  []string{"Network", "Office"},
+		Binary:      binary,
+		IconDir:     iconDir,
+		ModTime:     time.Unix(1700000000, 0),
+	}
+}
+
+// readTarGz returns the regular files of a gzip compressed tar archive
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Invalid gzip stream: %v", err)
+	}
+
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar archive: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			content, _ := io.ReadAll(tr)
+			files[hdr.Name] = string(content)
+		}
+	}
+	return files
+}
+
+func TestPackageName(t *testing.T) {
+	tests := map[string]string{
+		"Test App":   "test-app",
+		"My_Wiki 2":  "my-wiki-2",
+		"---":        "pake-app",
+		"gitlab.com": "gitlab.com",
+	}
+	for name, want := range tests {
+		if got := PackageName(name); got != want {
+			t.Errorf("PackageName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := map[string]string{
+		"":                       "1.0.0",
+		"v1.2.3":                 "1.2.3",
+		"1.2.3-4-gabc1234":       "1.2.3.4.gabc1234",
+		"1.2.3-4-gabc1234-dirty": "1.2.3.4.gabc1234~dirty",
+		"1.2.3-rc1":              "1.2.3~rc1",
+		"1.2.3-dirty":            "1.2.3~dirty",
+		"1.2.3-rc-1+build_5":     "1.2.3~rc.1+build.5",
+		"abc1234":                "0.0.0.abc1234",
+		"abc1234-dirty":          "0.0.0.abc1234.dirty",
+		"1.0.0+build_5":          "1.0.0+build.5",
+		"--":                     "1.0.0",
+	}
+	for version, want := range tests {
+		if got := (&Info{Version: version}).version(); got != want {
+			t.Errorf("version of %q = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestVersionOrder(t *testing.T) {
+	if _, err := exec.LookPath("dpkg"); err != nil {
+		t.Skip("dpkg not in PATH")
+	}
+
+	// The second version of each pair must upgrade over the first
+	tests := [][2]string{
+		{"1.2.3-rc1", "1.2.3-rc2"},
+		{"1.2.3-rc2", "1.2.3"},
+		{"1.2.3-dirty", "1.2.3"},
+		{"1.2.3", "1.2.3-4-gabc1234-dirty"},
+		{"1.2.3-4-gabc1234-dirty", "1.2.3-4-gabc1234"},
+		{"1.2.3-4-gabc1234", "1.2.4-beta"},
+		{"1.2.4-beta", "1.2.4"},
+	}
+	for _, tt := range tests {
+		older := (&Info{Version: tt[0]}).version()
+		newer := (&Info{Version: tt[1]}).version()
+		if err := exec.Command("dpkg", "--compare-versions", older, "lt", newer).Run(); err != nil {
+			t.Errorf("Expected %s (%s) to sort before %s (%s)", older, tt[0], newer, tt[1])
+		}
+	}
+}
+
+func TestDesktop(t *testing.T) {
+	desktop := Desktop(testInfo(t))
+	for _, line := range []string{
+		"Name=Test App",
+		"Exec=test-app",
+		"Icon=test-app",
+		"Comment=Test application",
+		"Categories=Network;Office;",
+		"StartupWMClass=Test App",
+	} {
+		if !strings.Contains(desktop, line+"\n") {
+			t.Errorf("Expected %q in desktop entry:\n%s", line, desktop)
+		}
+	}
+}
+
+func TestBuildDeb(t *testing.T) {
+	path, err := BuildDeb(testInfo(t), t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to build deb: %v", err)
+	}
+	if filepath.Base(path) != "test-app_1.2.3_amd64.deb" {
+		t.Errorf("Unexpected deb name %s", filepath.Base(path))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("!<arch>\ndebian-binary   ")) {
+		t.Fatalf("Invalid ar header")
+	}
+
+	// Walk the ar members
+	members := make(map[string][]byte)
+	for offset := 8; offset < len(data); {
+		name := strings.TrimSpace(string(data[offset : offset+16]))
+		var size int
+		for _, c := range strings.TrimSpace(string(data[offset+48 : offset+58])) {
+			size = size*10 + int(c-'0')
+		}
+		members[name] = data[offset+60 : offset+60+size]
+		offset += 60 + size + size%2
+	}
+
+	if string(members["debian-binary"]) != "2.0\n" {
+		t.Errorf("Unexpected debian-binary %q", members["debian-binary"])
+	}
+
+	control := readTarGz(t, members["control.tar.gz"])
+	for _, line := range []string{"Package: test-app", "Version: 1.2.3", "Architecture: amd64", "Description: Test application", " Wraps the test site."} {
+		if !strings.Contains(control["./control"], line+"\n") {
+			t.Errorf("Expected %q in control:\n%s", line, control["./control"])
+		}
+	}
+	if !strings.Contains(control["./control"], "Depends: libgtk-3-0, libwebkit2gtk-4.0-37\n") {
+		t.Errorf("Expected the WebKitGTK 4.0 dependency in control:\n%s", control["./control"])
+	}
+	if !strings.Contains(control["./md5sums"], "usr/bin/test-app") {
+		t.Errorf("Expected binary in md5sums")
+	}
+
+	files := readTarGz(t, members["data.tar.gz"])
+	for _, name := range []string{
+		"./usr/bin/test-app",
+		"./usr/share/applications/test-app.desktop",
+		"./usr/share/icons/hicolor/32x32/apps/test-app.png",
+		"./usr/share/icons/hicolor/256x256/apps/test-app.png",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in data.tar.gz", name)
+		}
+	}
+}
+
+func TestDebControlWebKit41(t *testing.T) {
+	info := testInfo(t)
+	info.WebKit41 = true
+	if control := debControl(info, "amd64", nil); !strings.Contains(control, "Depends: libgtk-3-0, libwebkit2gtk-4.1-0\n") {
+		t.Errorf("Expected the WebKitGTK 4.1 dependency in control:\n%s", control)
+	}
+}
+
+func TestBuildTarball(t *testing.T) {
+	path, err := BuildTarball(testInfo(t), t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to build tarball: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readTarGz(t, data)
+
+	root := "test-app-1.2.3-linux-amd64/"
+	if !strings.Contains(files[root+"AppRun"], `"$HERE/usr/bin/test-app"`) {
+		t.Errorf("Expected AppRun launcher, got %q", files[root+"AppRun"])
+	}
+	if files[root+"test-app.png"] != "png 256x256" {
+		t.Errorf("Expected the largest icon next to AppRun")
+	}
+	if _, ok := files[root+"usr/bin/test-app"]; !ok {
+		t.Errorf("Expected binary in usr/bin")
+	}
+}
+
+func TestBuildRPM(t *testing.T) {
+	path, err := BuildRPM(testInfo(t), t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to build rpm: %v", err)
+	}
+	if filepath.Base(path) != "test-app-1.2.3-1.x86_64.rpm" {
+		t.Errorf("Unexpected rpm name %s", filepath.Base(path))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte{0xed, 0xab, 0xee, 0xdb}) {
+		t.Fatalf("Invalid rpm lead")
+	}
+
+	// Skip the signature header, padded to 8 bytes, to reach the main header
+	offset := 96
+	readHeader := func() (int, int) {
+		if !bytes.HasPrefix(data[offset:], []byte{0x8e, 0xad, 0xe8, 0x01}) {
+			t.Fatalf("Invalid header magic at %d", offset)
+		}
+		count := int(binary.BigEndian.Uint32(data[offset+8:]))
+		size := int(binary.BigEndian.Uint32(data[offset+12:]))
+		return count, 16 + count*16 + size
+	}
+	_, sigSize := readHeader()
+	offset += (sigSize + 7) &^ 7
+	_, headerSize := readHeader()
+	offset += headerSize
+
+	gz, err := gzip.NewReader(bytes.NewReader(data[offset:]))
+	if err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	cpio, _ := io.ReadAll(gz)
+	for _, name := range []string{"./usr/bin/test-app", "./usr/share/applications/test-app.desktop", "TRAILER!!!"} {
+		if !bytes.Contains(cpio, []byte(name+"\x00")) {
+			t.Errorf("Expected %s in payload", name)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package packager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultRPMDepends are the runtime libraries a Wails application needs on Fedora
+var DefaultRPMDepends = []string{"gtk3", "webkit2gtk4.0"}
+
+// RPMDependsWebKit41 are the runtime libraries of an application built with
+// the webkit2_41 tag
+var RPMDependsWebKit41 = []string{"gtk3", "webkit2gtk4.1"}
+
+// rpmArch maps Go architectures to RPM ones
+var rpmArch = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+	"386":   "i686",
+	"arm":   "armv7hl",
+}
+
+// RPM header entry types
+const (
+	rpmInt16       = 3
+	rpmInt32       = 4
+	rpmString      = 6
+	rpmBin         = 7
+	rpmStringArray = 8
+	rpmI18NString  = 9
+)
+
+// RPM header and signature tags
+const (
+	tagHeaderSignatures = 62
+	tagHeaderImmutable  = 63
+
+	sigTagSHA1        = 269
+	sigTagSHA256      = 273
+	sigTagSize        = 1000
+	sigTagMD5         = 1004
+	sigTagPayloadSize = 1007
+
+	tagName              = 1000
+	tagVersion           = 1001
+	tagRelease           = 1002
+	tagSummary           = 1004
+	tagDescription       = 1005
+	tagBuildTime         = 1006
+	tagBuildHost         = 1007
+	tagSize              = 1009
+	tagLicense           = 1014
+	tagGroup             = 1016
+	tagURL               = 1020
+	tagOS                = 1021
+	tagArch              = 1022
+	tagFileSizes         = 1028
+	tagFileModes         = 1030
+	tagFileRdevs         = 1033
+	tagFileMtimes        = 1034
+	tagFileDigests       = 1035
+	tagFileLinkTos       = 1036
+	tagFileFlags         = 1037
+	tagFileUserName      = 1039
+	tagFileGroupName     = 1040
+	tagProvideName       = 1047
+	tagRequireFlags      = 1048
+	tagRequireName       = 1049
+	tagRequireVersion    = 1050
+	tagFileVerifyFlags   = 1045
+	tagRPMVersion        = 1064
+	tagFileDevices       = 1095
+	tagFileInodes        = 1096
+	tagFileLangs         = 1097
+	tagProvideFlags      = 1112
+	tagProvideVersion    = 1113
+	tagDirIndexes        = 1116
+	tagBaseNames         = 1117
+	tagDirNames          = 1118
+	tagPayloadFormat     = 1124
+	tagPayloadCompressor = 1125
+	tagPayloadFlags      = 1126
+	tagFileDigestAlgo    = 5011
+)
+
+// Dependency sense flags
+const (
+	senseLess   = 0x02
+	senseEqual  = 0x08
+	senseRPMLib = 0x1000000
+)
+
+// rpmEntry is a tag of an RPM header
+type rpmEntry struct {
+	tag   int32
+	kind  int32
+	count int32
+	data  []byte
+}
+
+// rpmHeader builds an RPM header structure
+type rpmHeader struct {
+	entries []rpmEntry
+}
+
+func (h *rpmHeader) add(tag, kind int32, count int, data []byte) {
+	h.entries = append(h.entries, rpmEntry{tag: tag, kind: kind, count: int32(count), data: data})
+}
+
+func (h *rpmHeader) addString(tag int32, value string) {
+	h.add(tag, rpmString, 1, append([]byte(value), 0))
+}
+
+func (h *rpmHeader) addI18N(tag int32, value string) {
+	h.add(tag, rpmI18NString, 1, append([]byte(value), 0))
+}
+
+func (h *rpmHeader) addStrings(tag int32, values []string) {
+	var buf bytes.Buffer
+	for _, v := range values {
+		buf.WriteString(v)
+		buf.WriteByte(0)
+	}
+	h.add(tag, rpmStringArray, len(values), buf.Bytes())
+}
+
+func (h *rpmHeader) addInt32(tag int32, values ...int32) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, values)
+	h.add(tag, rpmInt32, len(values), buf.Bytes())
+}
+
+func (h *rpmHeader) addInt16(tag int32, values ...int16) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, values)
+	h.add(tag, rpmInt16, len(values), buf.Bytes())
+}
+
+func (h *rpmHeader) addBin(tag int32, data []byte) {
+	h.add(tag, rpmBin, len(data), data)
+}
+
+// marshal serializes the header with an immutable region tag
+func (h *rpmHeader) marshal(regionTag int32) []byte {
+	entries := append([]rpmEntry(nil), h.entries...)
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].tag < entries[b].tag
+	})
+
+	var index, store bytes.Buffer
+	count := len(entries) + 1
+
+	writeIndex := func(tag, kind, offset, n int32) {
+		binary.Write(&index, binary.BigEndian, []int32{tag, kind, offset, n})
+	}
+
+	// The region tag comes first in the index; its data, written last,
+	// points back at the start of the index. The offset is patched below.
+	writeIndex(regionTag, rpmBin, 0, 16)
+
+	for _, e := range entries {
+		switch e.kind {
+		case rpmInt16:
+			pad(&store, 2)
+		case rpmInt32:
+			pad(&store, 4)
+		}
+		writeIndex(e.tag, e.kind, int32(store.Len()), e.count)
+		store.Write(e.data)
+	}
+
+	trailerOffset := int32(store.Len())
+	binary.Write(&store, binary.BigEndian, []int32{regionTag, rpmBin, int32(-16 * count), 16})
+
+	out := index.Bytes()
+	binary.BigEndian.PutUint32(out[8:], uint32(trailerOffset))
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	binary.Write(&buf, binary.BigEndian, []int32{int32(count), int32(store.Len())})
+	buf.Write(out)
+	buf.Write(store.Bytes())
+	return buf.Bytes()
+}
+
+// pad aligns buf to a multiple of n bytes
+func pad(buf *bytes.Buffer, n int) {
+	for buf.Len()%n != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+// BuildRPM writes an .rpm package into outDir and returns its path
+func BuildRPM(i *Info, outDir string) (string, error) {
+	files, err := i.files()
+	if err != nil {
+		return "", err
+	}
+
+	arch, ok := rpmArch[i.Arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported rpm architecture %q", i.Arch)
+	}
+
+	version, release := i.version(), "1"
+	mtime := int32(i.ModTime.Unix())
+
+	// Payload: a gzip compressed cpio archive
+	var cpio bytes.Buffer
+	for n, f := range files {
+		writeCPIO(&cpio, "."+f.path, uint32(n+1), uint32(0100000|f.mode.Perm()), uint32(mtime), f.data)
+	}
+	writeCPIO(&cpio, "TRAILER!!!", 0, 0, 0, nil)
+
+	var payload bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&payload, gzip.BestCompression)
+	gz.ModTime = i.ModTime
+	gz.Write(cpio.Bytes())
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	// File metadata, with paths split into directory names and base names
+	var dirNames []string
+	dirIndex := make(map[string]int32)
+	var (
+		baseNames, digests, linkTos, users, groups, langs []string
+		sizes, mtimes, flags, verify, devices, inodes     []int32
+		dirIndexes                                        []int32
+		modes, rdevs                                      []int16
+	)
+	for n, f := range files {
+		dir := path.Dir(f.path) + "/"
+		if _, ok := dirIndex[dir]; !ok {
+			dirIndex[dir] = int32(len(dirNames))
+			dirNames = append(dirNames, dir)
+		}
+		sum := sha256.Sum256(f.data)
+
+		baseNames = append(baseNames, path.Base(f.path))
+		dirIndexes = append(dirIndexes, dirIndex[dir])
+		digests = append(digests, hex.EncodeToString(sum[:]))
+		linkTos = append(linkTos, "")
+		users = append(users, "root")
+		groups = append(groups, "root")
+		langs = append(langs, "")
+		sizes = append(sizes, int32(len(f.data)))
+		mtimes = append(mtimes, mtime)
+		flags = append(flags, 0)
+		verify = append(verify, -1)
+		devices = append(devices, 1)
+		inodes = append(inodes, int32(n+1))
+		modes = append(modes, int16(0100000|f.mode.Perm()))
+		rdevs = append(rdevs, 0)
+	}
+
+	depends := i.Depends
+	if len(depends) == 0 {
+		depends = DefaultRPMDepends
+		if i.WebKit41 {
+			depends = RPMDependsWebKit41
+		}
+	}
+	requireNames := append([]string{
+		"rpmlib(CompressedFileNames)",
+		"rpmlib(FileDigests)",
+		"rpmlib(PayloadFilesHavePrefix)",
+	}, depends...)
+	requireFlags := []int32{senseRPMLib | senseLess | senseEqual, senseRPMLib | senseLess | senseEqual, senseRPMLib | senseLess | senseEqual}
+	requireVersions := []string{"3.0.4-1", "4.6.0-1", "4.0-1"}
+	for range depends {
+		requireFlags = append(requireFlags, 0)
+		requireVersions = append(requireVersions, "")
+	}
+
+	license := i.License
+	if license == "" {
+		license = "Proprietary"
+	}
+	description := i.Description
+	if description == "" {
+		description = i.summary()
+	}
+
+	header := &rpmHeader{}
+	header.addString(tagName, i.Name)
+	header.addString(tagVersion, version)
+	header.addString(tagRelease, release)
+	header.addI18N(tagSummary, i.summary())
+	header.addI18N(tagDescription, description)
+	header.addInt32(tagBuildTime, mtime)
+	header.addString(tagBuildHost, "localhost")
+	header.addInt32(tagSize, int32(installedSize(files)))
+	header.addString(tagLicense, license)
+	header.addI18N(tagGroup, "Applications/Internet")
+	if i.Homepage != "" {
+		header.addString(tagURL, i.Homepage)
+	}
+	header.addString(tagOS, "linux")
+	header.addString(tagArch, arch)
+	header.addInt32(tagFileSizes, sizes...)
+	header.addInt16(tagFileModes, modes...)
+	header.addInt16(tagFileRdevs, rdevs...)
+	header.addInt32(tagFileMtimes, mtimes...)
+	header.addStrings(tagFileDigests, digests)
+	header.addStrings(tagFileLinkTos, linkTos)
+	header.addInt32(tagFileFlags, flags...)
+	header.addStrings(tagFileUserName, users)
+	header.addStrings(tagFileGroupName, groups)
+	header.addInt32(tagFileVerifyFlags, verify...)
+	header.addStrings(tagProvideName, []string{i.Name, i.Name + "(" + arch + ")"})
+	header.addInt32(tagProvideFlags, senseEqual, senseEqual)
+	header.addStrings(tagProvideVersion, []string{version + "-" + release, version + "-" + release})
+	header.addInt32(tagRequireFlags, requireFlags...)
+	header.addStrings(tagRequireName, requireNames)
+	header.addStrings(tagRequireVersion, requireVersions)
+	header.addString(tagRPMVersion, "4.16.0")
+	header.addInt32(tagFileDevices, devices...)
+	header.addInt32(tagFileInodes, inodes...)
+	header.addStrings(tagFileLangs, langs)
+	header.addInt32(tagDirIndexes, dirIndexes...)
+	header.addStrings(tagBaseNames, baseNames)
+	header.addStrings(tagDirNames, dirNames)
+	header.addString(tagPayloadFormat, "cpio")
+	header.addString(tagPayloadCompressor, "gzip")
+	header.addString(tagPayloadFlags, "9")
+	header.addInt32(tagFileDigestAlgo, 8) // SHA-256
+	headerBytes := header.marshal(tagHeaderImmutable)
+
+	// Signature: sizes and digests of the header and payload
+	headerSHA1 := sha1.Sum(headerBytes)
+	headerSHA256 := sha256.Sum256(headerBytes)
+	md5sum := md5.New()
+	md5sum.Write(headerBytes)
+	md5sum.Write(payload.Bytes())
+
+	signature := &rpmHeader{}
+	signature.addString(sigTagSHA1, hex.EncodeToString(headerSHA1[:]))
+	signature.addString(sigTagSHA256, hex.EncodeToString(headerSHA256[:]))
+	signature.addInt32(sigTagSize, int32(len(headerBytes)+payload.Len()))
+	signature.addBin(sigTagMD5, md5sum.Sum(nil))
+	signature.addInt32(sigTagPayloadSize, int32(cpio.Len()))
+	signatureBytes := signature.marshal(tagHeaderSignatures)
+
+	var rpm bytes.Buffer
+	rpm.Write(rpmLead(i.Name + "-" + version + "-" + release))
+	rpm.Write(signatureBytes)
+	pad(&rpm, 8)
+	rpm.Write(headerBytes)
+	rpm.Write(payload.Bytes())
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	out := filepath.Join(outDir, fmt.Sprintf("%s-%s-%s.%s.rpm", i.Name, version, release, arch))
+	return out, writeFile(out, rpm.Bytes())
+}
+
+// rpmLead returns the legacy 96 byte lead of an RPM file
+func rpmLead(name string) []byte {
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	binary.BigEndian.PutUint16(lead[6:], 0) // binary package
+	binary.BigEndian.PutUint16(lead[8:], 1) // architecture, unused by rpm 4
+	copy(lead[10:75], name)
+	binary.BigEndian.PutUint16(lead[76:], 1) // linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // header-style signature
+	return lead
+}
+
+// writeCPIO appends an entry in the "newc" cpio format
+func writeCPIO(buf *bytes.Buffer, name string, ino, mode, mtime uint32, data []byte) {
+	nlink := uint32(1)
+	if name == "TRAILER!!!" {
+		nlink = 0
+	}
+
+	fmt.Fprintf(buf, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		ino, mode, 0, 0, nlink, mtime, len(data), 0, 0, 0, 0, len(name)+1, 0)
+	buf.WriteString(name)
+	buf.WriteByte(0)
+	pad(buf, 4)
+	buf.Write(data)
+	pad(buf, 4)
+}
@@ -0,0 +1,55 @@
+package packager
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BuildTarball writes a portable, AppImage-style AppDir as a .tar.gz into
+// outDir and returns its path. The archive unpacks into a single directory
+// with an AppRun launcher, the desktop entry and icon next to a usr/ tree.
+func BuildTarball(i *Info, outDir string) (string, error) {
+	files, err := i.files()
+	if err != nil {
+		return "", err
+	}
+
+	root := fmt.Sprintf("%s-%s-linux-%s", i.Name, i.version(), i.Arch)
+	appRun := fmt.Sprintf("#!/bin/sh\nHERE=\"$(dirname \"$(readlink -f \"$0\")\")\"\nexec \"$HERE/usr/bin/%s\" \"$@\"\n", i.Name)
+
+	data, err := tarGz(i.ModTime, func(tw *tar.Writer) error {
+		if err := writeTarFile(tw, root+"/AppRun", 0755, []byte(appRun), i.ModTime); err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, root+"/"+i.Name+".desktop", 0644, []byte(Desktop(i)), i.ModTime); err != nil {
+			return err
+		}
+
+		iconPath, err := i.largestIcon()
+		if err != nil {
+			return err
+		}
+		if iconPath != "" {
+			icon, err := os.ReadFile(iconPath)
+			if err != nil {
+				return err
+			}
+			if err := writeTarFile(tw, root+"/"+i.Name+".png", 0644, icon, i.ModTime); err != nil {
+				return err
+			}
+		}
+
+		return writeTarTree(tw, root, files, i.ModTime)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+	out := filepath.Join(outDir, root+".tar.gz")
+	return out, writeFile(out, data)
+}
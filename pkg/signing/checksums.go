@@ -0,0 +1,104 @@
+package signing
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFile is the name of the checksum list, SignatureFile the name of
+// its detached signature
+const (
+	ChecksumsFile = "SHA256SUMS"
+	SignatureFile = ChecksumsFile + ".sig"
+)
+
+// FormatChecksums renders hashes, keyed by slash separated path, in the
+// format of sha256sum
+func FormatChecksums(hashes map[string]string) []byte {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", hashes[name], name)
+	}
+	return buf.Bytes()
+}
+
+// ParseChecksums parses a checksum list in the format of sha256sum
+func ParseChecksums(data []byte) (map[string]string, error) {
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(text, " ")
+		name = strings.TrimPrefix(name, " ")
+		name = strings.TrimPrefix(name, "*")
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("line %d: malformed checksum", line)
+		}
+		hashes[name] = strings.ToLower(sum)
+	}
+	return hashes, scanner.Err()
+}
+
+// FileSHA256 returns the hex encoded SHA-256 hash of a file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CheckFiles compares the files named in hashes, relative to dir, with
+// their hashes. Only the given names are checked when there are any.
+func CheckFiles(dir string, hashes map[string]string, names ...string) error {
+	if len(names) == 0 {
+		for name := range hashes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var failures []string
+	for _, name := range names {
+		name = filepath.ToSlash(name)
+		want, ok := hashes[name]
+		if !ok {
+			failures = append(failures, name+" is not listed")
+			continue
+		}
+		got, err := FileSHA256(filepath.Join(dir, filepath.FromSlash(name)))
+		switch {
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		case got != want:
+			failures = append(failures, name+" does not match its checksum")
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("checksum verification failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package signing creates and checks SHA256SUMS files and detached ed25519
+// signatures. Public keys and signatures use the minisign format, so they
+// can also be checked with minisign -V.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// algorithm identifies ed25519 signatures of the whole message
+var algorithm = [2]byte{'E', 'd'}
+
+// PublicKey verifies signatures
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+// PrivateKey creates signatures
+type PrivateKey struct {
+	ID  [8]byte
+	Key ed25519.PrivateKey
+}
+
+// GenerateKey creates a new key pair with a random key ID
+func GenerateKey() (*PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	priv := &PrivateKey{Key: key}
+	if _, err := rand.Read(priv.ID[:]); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+// Public returns the public key of k
+func (k *PrivateKey) Public() *PublicKey {
+	return &PublicKey{ID: k.ID, Key: k.Key.Public().(ed25519.PublicKey)}
+}
+
+// KeyID returns the key ID as printed by minisign
+func (k *PublicKey) KeyID() string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(k.ID[:]))
+}
+
+// Encode returns the public key file contents
+func (k *PublicKey) Encode() []byte {
+	data := append(append(algorithm[:], k.ID[:]...), k.Key...)
+	return []byte(fmt.Sprintf("untrusted comment: minisign public key %s\n%s\n",
+		k.KeyID(), base64.StdEncoding.EncodeToString(data)))
+}
+
+// Encode returns the private key file contents. The key is not encrypted;
+// keep the file private.
+func (k *PrivateKey) Encode() []byte {
+	data := append(append(algorithm[:], k.ID[:]...), k.Key...)
+	return []byte(fmt.Sprintf("untrusted comment: pake-go secret key %s\n%s\n",
+		k.Public().KeyID(), base64.StdEncoding.EncodeToString(data)))
+}
+
+// ParsePublicKey parses a public key file, or a bare base64 public key
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	raw, err := decodeKeyLine(data, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	key := &PublicKey{Key: ed25519.PublicKey(raw[10:])}
+	copy(key.ID[:], raw[2:10])
+	return key, nil
+}
+
+// ParsePrivateKey parses a private key file written by Encode
+func ParsePrivateKey(data []byte) (*PrivateKey, error) {
+	raw, err := decodeKeyLine(data, ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	key := &PrivateKey{Key: ed25519.PrivateKey(raw[10:])}
+	copy(key.ID[:], raw[2:10])
+	return key, nil
+}
+
+// LoadPublicKey reads a public key file
+func LoadPublicKey(path string) (*PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKey(data)
+}
+
+// LoadPrivateKey reads a private key file
+func LoadPrivateKey(path string) (*PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(data)
+}
+
+// decodeKeyLine decodes the key line of a key file, skipping comments, and
+// checks the algorithm and size
+func decodeKeyLine(data []byte, size int) ([]byte, error) {
+	var line string
+	for _, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+			break
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 2+8+size {
+		return nil, fmt.Errorf("unexpected key length %d", len(raw))
+	}
+	if !bytes.Equal(raw[:2], algorithm[:]) {
+		return nil, fmt.Errorf("unsupported algorithm %q", raw[:2])
+	}
+	return raw, nil
+}
+
+// Sign returns a detached signature of message. The trusted comment is
+// signed too and returned by Verify.
+func Sign(key *PrivateKey, message []byte, trustedComment string) []byte {
+	sig := ed25519.Sign(key.Key, message)
+	sigLine := append(append(algorithm[:], key.ID[:]...), sig...)
+	globalSig := ed25519.Sign(key.Key, append(append([]byte{}, sig...), trustedComment...))
+
+	return []byte(fmt.Sprintf("untrusted comment: signature from pake-go secret key %s\n%s\ntrusted comment: %s\n%s\n",
+		key.Public().KeyID(),
+		base64.StdEncoding.EncodeToString(sigLine),
+		trustedComment,
+		base64.StdEncoding.EncodeToString(globalSig)))
+}
+
+// Verify checks a detached signature of message made by key and returns its
+// trusted comment
+func Verify(key *PublicKey, message []byte, signature []byte) (string, error) {
+	lines := strings.Split(strings.TrimRight(string(signature), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("malformed signature")
+	}
+
+	sigLine, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sigLine) != 2+8+ed25519.SignatureSize {
+		return "", errors.New("malformed signature")
+	}
+	if !bytes.Equal(sigLine[:2], algorithm[:]) {
+		return "", fmt.Errorf("unsupported signature algorithm %q", sigLine[:2])
+	}
+	if !bytes.Equal(sigLine[2:10], key.ID[:]) {
+		return "", errors.New("signature was made by a different key")
+	}
+	sig := sigLine[10:]
+	if !ed25519.Verify(key.Key, message, sig) {
+		return "", errors.New("signature does not match")
+	}
+
+	trustedComment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(key.Key, append(append([]byte{}, sig...), trustedComment...), globalSig) {
+		return "", errors.New("trusted comment does not match the signature")
+	}
+	return trustedComment, nil
+}
@@ -0,0 +1,90 @@
+package signing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	// Keys survive encoding
+	parsedKey, err := ParsePrivateKey(key.Encode())
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	pub, err := ParsePublicKey(key.Public().Encode())
+	if err != nil {
+		t.Fatalf("Failed to parse public key: %v", err)
+	}
+	if pub.KeyID() != key.Public().KeyID() {
+		t.Errorf("Expected key ID %s, got %s", key.Public().KeyID(), pub.KeyID())
+	}
+
+	message := []byte("abc  bin/App\n")
+	sig := Sign(parsedKey, message, "timestamp:0\tfile:SHA256SUMS")
+	comment, err := Verify(pub, message, sig)
+	if err != nil {
+		t.Fatalf("Failed to verify signature: %v", err)
+	}
+	if comment != "timestamp:0\tfile:SHA256SUMS" {
+		t.Errorf("Unexpected trusted comment %q", comment)
+	}
+
+	if _, err := Verify(pub, []byte("tampered"), sig); err == nil {
+		t.Errorf("Expected a tampered message to fail")
+	}
+	forged := strings.Replace(string(sig), "timestamp:0", "timestamp:1", 1)
+	if _, err := Verify(pub, message, []byte(forged)); err == nil {
+		t.Errorf("Expected a tampered trusted comment to fail")
+	}
+	other, _ := GenerateKey()
+	if _, err := Verify(other.Public(), message, sig); err == nil {
+		t.Errorf("Expected a different key to fail")
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "App"), []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := FileSHA256(filepath.Join(dir, "bin", "App"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := FormatChecksums(map[string]string{"bin/App": sum})
+	if want := sum + "  bin/App\n"; string(data) != want {
+		t.Errorf("Expected sha256sum format %q, got %q", want, data)
+	}
+	hashes, err := ParseChecksums(data)
+	if err != nil || hashes["bin/App"] != sum {
+		t.Fatalf("Failed to parse checksums: %v, %v", hashes, err)
+	}
+	if err := CheckFiles(dir, hashes); err != nil {
+		t.Errorf("Expected checksums to match: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bin", "App"), []byte("patched"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckFiles(dir, hashes, "bin/App"); err == nil {
+		t.Errorf("Expected a modified file to fail")
+	}
+	if err := CheckFiles(dir, hashes, "bin/Other"); err == nil {
+		t.Errorf("Expected an unlisted file to fail")
+	}
+
+	if _, err := ParseChecksums([]byte("xyz  bin/App\n")); err == nil {
+		t.Errorf("Expected a malformed line to fail")
+	}
+}
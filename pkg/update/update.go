@@ -0,0 +1,205 @@
+// Package update publishes the release feed generated apps update from
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/signing"
+)
+
+// Feed lists the latest release of every channel. The generated update.go
+// decodes the same format.
+type Feed struct {
+	Name     string              `json:"name"`
+	Channels map[string]*Release `json:"channels"`
+}
+
+// Release is a version of the app with a binary per platform
+type Release struct {
+	Version   string    `json:"version"`
+	Published time.Time `json:"published"`
+	Notes     string    `json:"notes,omitempty"`
+	// Platforms maps os/arch to the binary for it
+	Platforms map[string]*Asset `json:"platforms"`
+}
+
+// Asset is a signed app binary
+type Asset struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	// Signature is a detached signature of the binary, see signing.Sign
+	Signature string `json:"signature"`
+}
+
+// LoadFeed reads a feed file, returning an empty feed when it does not exist
+func LoadFeed(path string) (*Feed, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Feed{Channels: map[string]*Release{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var feed Feed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+	if feed.Channels == nil {
+		feed.Channels = map[string]*Release{}
+	}
+	return &feed, nil
+}
+
+// Save writes the feed file
+func (f *Feed) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Options describes a release to publish
+type Options struct {
+	// OutputDir is the build output directory holding the built apps
+	OutputDir string
+	// BaseURL is where the contents of OutputDir are uploaded
+	BaseURL string
+	Channel string
+	Notes   string
+	// HostPlatform is the platform of apps built without -platform
+	HostPlatform string
+	Key          *signing.PrivateKey
+	// Time is recorded as the publishing time; zero uses the current time
+	Time time.Time
+}
+
+// Publish signs the app binaries built for cfg and records them as the
+// latest release of the channel in feed
+func Publish(feed *Feed, cfg *config.Config, opts Options) (*Release, error) {
+	base, err := url.Parse(opts.BaseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q", opts.BaseURL)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	if opts.Channel == "" {
+		opts.Channel = config.DefaultUpdateChannel
+	}
+	if opts.HostPlatform == "" {
+		opts.HostPlatform = runtime.GOOS + "/" + runtime.GOARCH
+	}
+	if opts.Time.IsZero() {
+		opts.Time = time.Now().UTC()
+	}
+
+	binaries, err := findBinaries(opts.OutputDir, cfg.Name, opts.HostPlatform)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{
+		Version:   cfg.AppVersion(),
+		Published: opts.Time,
+		Notes:     opts.Notes,
+		Platforms: map[string]*Asset{},
+	}
+	for platform, rel := range binaries {
+		file := filepath.Join(opts.OutputDir, filepath.FromSlash(rel))
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := signing.FileSHA256(file)
+		if err != nil {
+			return nil, err
+		}
+		comment := fmt.Sprintf("file:%s\tversion:%s\tplatform:%s", path.Base(rel), release.Version, platform)
+		release.Platforms[platform] = &Asset{
+			URL:       base.ResolveReference(&url.URL{Path: rel}).String(),
+			SHA256:    sum,
+			Size:      int64(len(data)),
+			Signature: string(signing.Sign(opts.Key, data, comment)),
+		}
+	}
+
+	if feed.Name == "" {
+		feed.Name = cfg.Name
+	}
+	feed.Channels[opts.Channel] = release
+	return release, nil
+}
+
+// findBinaries returns the app executables in outputDir keyed by platform,
+// as slash separated paths relative to outputDir. Apps built for several
+// platforms are in bin/<os>-<arch>, an app built for the host in bin. macOS
+// apps are skipped: replacing the executable of a signed bundle breaks its
+// signature, so generated apps do not update on macOS.
+func findBinaries(outputDir string, name string, hostPlatform string) (map[string]string, error) {
+	binDir := filepath.Join(outputDir, "bin")
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built apps: %w", err)
+	}
+
+	binaries := map[string]string{}
+	for _, entry := range entries {
+		goos, goarch, ok := strings.Cut(entry.Name(), "-")
+		if !entry.IsDir() || !ok || strings.Contains(goarch, "-") || goos == "darwin" {
+			continue
+		}
+		if rel, ok := executable(outputDir, "bin/"+entry.Name(), name, goos); ok {
+			binaries[goos+"/"+goarch] = rel
+		}
+	}
+	if len(binaries) == 0 {
+		hostOS, _, _ := strings.Cut(hostPlatform, "/")
+		if rel, ok := executable(outputDir, "bin", name, hostOS); ok && hostOS != "darwin" {
+			binaries[hostPlatform] = rel
+		}
+	}
+	if len(binaries) == 0 {
+		return nil, fmt.Errorf("no app named %s in %s", name, binDir)
+	}
+	return binaries, nil
+}
+
+// executable returns the path of the executable of the app built for goos
+// in the directory rel of outputDir
+func executable(outputDir string, rel string, name string, goos string) (string, bool) {
+	switch goos {
+	case "windows":
+		rel = path.Join(rel, name+".exe")
+	default:
+		rel = path.Join(rel, name)
+	}
+	info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(rel)))
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return rel, true
+}
+
+// SortedPlatforms returns the platforms of a release in order
+func (r *Release) SortedPlatforms() []string {
+	platforms := make([]string, 0, len(r.Platforms))
+	for platform := range r.Platforms {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	return platforms
+}
@@ -0,0 +1,110 @@
+package update
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zk3151463/pake-go/pkg/config"
+	"github.com/zk3151463/pake-go/pkg/signing"
+)
+
+func writeFile(t *testing.T, path string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	key, err := signing.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Name: "My App", Version: "1.2.0"}
+
+	outputDir := t.TempDir()
+	writeFile(t, filepath.Join(outputDir, "bin", "linux-amd64", "My App"), "linux")
+	writeFile(t, filepath.Join(outputDir, "bin", "windows-amd64", "My App.exe"), "windows")
+	writeFile(t, filepath.Join(outputDir, "bin", "darwin-universal", "My App.app", "Contents", "MacOS", "My App"), "darwin")
+
+	feedPath := filepath.Join(outputDir, "feed.json")
+	feed, err := LoadFeed(feedPath)
+	if err != nil {
+		t.Fatalf("Failed to load missing feed: %v", err)
+	}
+	release, err := Publish(feed, cfg, Options{
+		OutputDir: outputDir,
+		BaseURL:   "https://dl.example.com/app",
+		Channel:   "beta",
+		Key:       key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	// macOS apps are not published, see findBinaries
+	want := map[string]struct{ url, data string }{
+		"linux/amd64":   {"https://dl.example.com/app/bin/linux-amd64/My%20App", "linux"},
+		"windows/amd64": {"https://dl.example.com/app/bin/windows-amd64/My%20App.exe", "windows"},
+	}
+	if len(release.Platforms) != len(want) {
+		t.Fatalf("Expected %d platforms, got %v", len(want), release.SortedPlatforms())
+	}
+	for platform, w := range want {
+		asset := release.Platforms[platform]
+		if asset == nil || asset.URL != w.url {
+			t.Errorf("Expected %s at %s, got %+v", platform, w.url, asset)
+			continue
+		}
+		if _, err := signing.Verify(key.Public(), []byte(w.data), []byte(asset.Signature)); err != nil {
+			t.Errorf("Expected a valid signature for %s: %v", platform, err)
+		}
+	}
+
+	// Channels accumulate in the saved feed
+	if err := feed.Save(feedPath); err != nil {
+		t.Fatal(err)
+	}
+	feed, err = LoadFeed(feedPath)
+	if err != nil {
+		t.Fatalf("Failed to load feed: %v", err)
+	}
+	cfg.Version = "1.1.0"
+	if _, err := Publish(feed, cfg, Options{OutputDir: outputDir, BaseURL: "https://dl.example.com/app/", Key: key}); err != nil {
+		t.Fatal(err)
+	}
+	if feed.Name != "My App" || feed.Channels["beta"].Version != "1.2.0" || feed.Channels["stable"].Version != "1.1.0" {
+		t.Errorf("Unexpected feed %+v", feed)
+	}
+}
+
+func TestPublishHostBuild(t *testing.T) {
+	key, _ := signing.GenerateKey()
+	outputDir := t.TempDir()
+	writeFile(t, filepath.Join(outputDir, "bin", "App"), "linux")
+
+	release, err := Publish(&Feed{Channels: map[string]*Release{}}, &config.Config{Name: "App"}, Options{
+		OutputDir:    outputDir,
+		BaseURL:      "https://dl.example.com",
+		HostPlatform: "linux/arm64",
+		Key:          key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if asset := release.Platforms["linux/arm64"]; asset == nil || asset.URL != "https://dl.example.com/bin/App" {
+		t.Errorf("Expected the host build, got %v", release.Platforms)
+	}
+
+	if _, err := Publish(&Feed{Channels: map[string]*Release{}}, &config.Config{Name: "Other"}, Options{
+		OutputDir: outputDir,
+		BaseURL:   "https://dl.example.com",
+		Key:       key,
+	}); err == nil {
+		t.Errorf("Expected an error without built apps")
+	}
+}
@@ -0,0 +1,216 @@
+package webview
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MatchType selects how a rule is matched against page URLs
+type MatchType string
+
+// Match types
+const (
+	// MatchExact matches the URL equal to the pattern
+	MatchExact MatchType = "exact"
+	// MatchPrefix matches URLs starting with the pattern
+	MatchPrefix MatchType = "prefix"
+	// MatchGlob matches URLs against a pattern such as *.example.com/app/*,
+	// see NewMatcher
+	MatchGlob MatchType = "glob"
+	// MatchRegex matches URLs the regular expression pattern matches
+	MatchRegex MatchType = "regex"
+	// MatchURL matches the parsed host, path and query, see NewURLMatcher
+	MatchURL MatchType = "url"
+	// MatchLegacy matches URLs containing the pattern anywhere. It matches
+	// more pages than intended and is kept for rules written for it.
+	MatchLegacy MatchType = "legacy"
+)
+
+// Matcher decides which pages a rule applies to. Matchers are created with
+// NewMatcher or NewURLMatcher; a literal only matches once it is compiled by
+// InsertRule or UpdateRule.
+type Matcher struct {
+	Type    MatchType
+	Pattern string
+	// Host, Path and Query are matched by MatchURL
+	Host  string
+	Path  string
+	Query map[string]string
+
+	host *regexp.Regexp
+	path *regexp.Regexp
+	re   *regexp.Regexp
+	// port is set for glob hosts with a port, which are matched against the
+	// host and port of the URL
+	port bool
+	// compiled is set by the constructors
+	compiled bool
+}
+
+// NewMatcher returns a matcher of the given type for pattern.
+//
+// Glob patterns are a host with an optional scheme, port and path, where *
+// matches any characters within the host or the path and ? a single one. A
+// host starting with *. also matches the domain itself, so
+// *.example.com/app/* matches https://example.com/app/ and
+// https://www.example.com/app/x?y=1 but not
+// https://evil.net/?x.example.com/app/. A host without a port matches any
+// port.
+func NewMatcher(typ MatchType, pattern string) (*Matcher, error) {
+	m := &Matcher{Type: typ, Pattern: pattern, compiled: true}
+	switch typ {
+	case MatchExact, MatchPrefix, MatchLegacy:
+	case MatchGlob:
+		scheme, rest, ok := strings.Cut(pattern, "://")
+		if !ok {
+			scheme, rest = "", pattern
+		}
+		host, path, _ := strings.Cut(rest, "/")
+		if host == "" {
+			return nil, fmt.Errorf("glob %q has no host", pattern)
+		}
+		m.Host = host
+		if scheme != "" {
+			m.Host = scheme + "://" + host
+		}
+		m.host = compileHostGlob(m.Host)
+		m.port = strings.Contains(host, ":")
+		if path != "" {
+			m.path = compileGlob("/"+path, ".*")
+		}
+	case MatchRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+		m.re = re
+	case MatchURL:
+		return nil, fmt.Errorf("url matchers are created with NewURLMatcher")
+	default:
+		return nil, fmt.Errorf("unknown match type %q", typ)
+	}
+	return m, nil
+}
+
+// NewURLMatcher returns a matcher of parsed URLs. host is a host name, where
+// *.example.com also matches example.com and its subdomains; path is a glob
+// matched against the whole path; every query parameter must have the given
+// value, or be present when the value is empty. Empty host and path match
+// any.
+func NewURLMatcher(host string, path string, query map[string]string) (*Matcher, error) {
+	if strings.ContainsAny(host, "/:") {
+		return nil, fmt.Errorf("invalid host %q", host)
+	}
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q does not start with /", path)
+	}
+	m := &Matcher{Type: MatchURL, Host: host, Path: path, Query: maps.Clone(query), compiled: true}
+	if host != "" {
+		m.host = compileHostGlob(host)
+	}
+	if path != "" {
+		m.path = compileGlob(path, ".*")
+	}
+	return m, nil
+}
+
+// compile returns a matcher created from the exported fields of m
+func (m *Matcher) compile() (*Matcher, error) {
+	if m.Type == MatchURL {
+		return NewURLMatcher(m.Host, m.Path, m.Query)
+	}
+	return NewMatcher(m.Type, m.Pattern)
+}
+
+// compileHostGlob compiles a host glob, optionally with a scheme
+func compileHostGlob(host string) *regexp.Regexp {
+	scheme, name, ok := strings.Cut(strings.ToLower(host), "://")
+	if !ok {
+		scheme, name = "", scheme
+	}
+	expr := "^"
+	if scheme != "" {
+		expr += regexp.QuoteMeta(scheme + "://")
+	}
+	if rest, ok := strings.CutPrefix(name, "*."); ok {
+		expr += `(?:[^/]*\.)?`
+		name = rest
+	}
+	return regexp.MustCompile(expr + globExpr(name, "[^/]*") + "$")
+}
+
+// compileGlob compiles a glob whose * matches star, anchored at both ends
+func compileGlob(glob string, star string) *regexp.Regexp {
+	return regexp.MustCompile("^" + globExpr(glob, star) + "$")
+}
+
+// globExpr returns the regular expression of a glob whose * matches star
+func globExpr(glob string, star string) string {
+	var expr strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(star)
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return expr.String()
+}
+
+// Match reports whether the rule applies to the page at rawURL. A matcher
+// that was not compiled matches nothing.
+func (m *Matcher) Match(rawURL string) bool {
+	if m == nil || !m.compiled {
+		return false
+	}
+	switch m.Type {
+	case MatchExact:
+		return rawURL == m.Pattern
+	case MatchPrefix:
+		return strings.HasPrefix(rawURL, m.Pattern)
+	case MatchRegex:
+		return m.re.MatchString(rawURL)
+	case MatchLegacy:
+		return strings.Contains(rawURL, m.Pattern)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if m.port {
+		host = strings.ToLower(u.Host)
+	}
+	if m.Type == MatchGlob && strings.Contains(m.Host, "://") {
+		host = strings.ToLower(u.Scheme) + "://" + host
+	}
+	if m.host != nil && !m.host.MatchString(host) {
+		return false
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if m.Type == MatchGlob && u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if m.path != nil && !m.path.MatchString(path) {
+		return false
+	}
+
+	query := u.Query()
+	for key, value := range m.Query {
+		if !query.Has(key) || (value != "" && query.Get(key) != value) {
+			return false
+		}
+	}
+	return true
+}
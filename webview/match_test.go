@@ -0,0 +1,108 @@
+package webview
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		typ     MatchType
+		pattern string
+		url     string
+		want    bool
+	}{
+		{MatchExact, "https://example.com/", "https://example.com/", true},
+		{MatchExact, "https://example.com/", "https://example.com/app", false},
+		{MatchPrefix, "https://example.com/app", "https://example.com/app/x", true},
+		{MatchPrefix, "https://example.com/app", "https://evil.net/https://example.com/app", false},
+		{MatchRegex, `^https://(www\.)?example\.com/`, "https://www.example.com/a", true},
+		{MatchRegex, `^https://(www\.)?example\.com/`, "https://example.com.evil.net/", false},
+		{MatchLegacy, "example.com", "https://notexample.com.evil.net/?x=example.com", true},
+
+		{MatchGlob, "*.example.com/app/*", "https://example.com/app/", true},
+		{MatchGlob, "*.example.com/app/*", "https://www.example.com/app/x/y?z=1", true},
+		{MatchGlob, "*.example.com/app/*", "https://www.example.com/other", false},
+		{MatchGlob, "*.example.com/app/*", "https://evil.net/?x.example.com/app/", false},
+		{MatchGlob, "*.example.com/app/*", "https://notexample.com/app/", false},
+		{MatchGlob, "example.com", "https://EXAMPLE.com:8443/any?q", true},
+		{MatchGlob, "example.com", "https://example.com.evil.net/", false},
+		{MatchGlob, "https://example.com/*", "http://example.com/", false},
+		{MatchGlob, "https://example.com/*", "https://example.com/", true},
+		{MatchGlob, "example.com/a?c", "https://example.com/abc", true},
+		{MatchGlob, "example.com:8080/*", "https://example.com:8080/app", true},
+		{MatchGlob, "example.com:8080/*", "https://example.com/app", false},
+		{MatchGlob, "https://*.example.com:8080", "https://www.example.com:8080/", true},
+		{MatchGlob, "example.com:8080", "https://example.com:8081/", false},
+	}
+	for _, tt := range tests {
+		m, err := NewMatcher(tt.typ, tt.pattern)
+		if err != nil {
+			t.Fatalf("NewMatcher(%s, %q): %v", tt.typ, tt.pattern, err)
+		}
+		if got := m.Match(tt.url); got != tt.want {
+			t.Errorf("%s %q matching %q = %v, want %v", tt.typ, tt.pattern, tt.url, got, tt.want)
+		}
+	}
+
+	invalid := map[MatchType]string{
+		MatchRegex: "(",
+		MatchGlob:  "/app/*",
+		MatchURL:   "example.com",
+		"fuzzy":    "example.com",
+	}
+	for typ, pattern := range invalid {
+		if _, err := NewMatcher(typ, pattern); err == nil {
+			t.Errorf("Expected an error for %s %q", typ, pattern)
+		}
+	}
+}
+
+func TestURLMatcher(t *testing.T) {
+	m, err := NewURLMatcher("*.example.com", "/app/*", map[string]string{"tab": "edit", "debug": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"https://app.example.com/app/doc?tab=edit&debug":      true,
+		"https://example.com/app/doc?debug=1&tab=edit":        true,
+		"https://app.example.com/app/doc?tab=view&debug":      false,
+		"https://app.example.com/app/doc?tab=edit":            false,
+		"https://app.example.com/other?tab=edit&debug":        false,
+		"https://evil.net/app/doc?tab=edit&debug&example.com": false,
+		"not a url": false,
+	}
+	for url, want := range tests {
+		if got := m.Match(url); got != want {
+			t.Errorf("Match(%q) = %v, want %v", url, got, want)
+		}
+	}
+
+	if _, err := NewURLMatcher("example.com/app", "", nil); err == nil {
+		t.Errorf("Expected an error for a host with a path")
+	}
+	if _, err := NewURLMatcher("example.com", "app", nil); err == nil {
+		t.Errorf("Expected an error for a relative path")
+	}
+}
+
+func TestGetRulesForURL(t *testing.T) {
+	w := NewWebViewManager()
+	w.AddRule("example.com", []string{"legacy{}"}, nil, nil)
+	m, _ := NewMatcher(MatchGlob, "*.example.com/app/*")
+	w.AddMatchedRule(m, []string{"app{}"}, []string{"app()"}, map[string]string{"X-App": "1"})
+
+	css, js, headers := w.GetRulesForURL("https://notexample.com.evil.net/?x=example.com")
+	if len(css) != 1 || css[0] != "legacy{}" || len(js) != 0 || len(headers) != 0 {
+		t.Errorf("Expected only the legacy rule, got %v %v %v", css, js, headers)
+	}
+	css, js, headers = w.GetRulesForURL("https://www.example.com/app/")
+	if len(css) != 2 || len(js) != 1 || headers["X-App"] != "1" {
+		t.Errorf("Expected both rules, got %v %v %v", css, js, headers)
+	}
+
+	// An invalid matcher is reported instead of silently never applying
+	if err := w.AddMatchedRule(&Matcher{Type: MatchRegex, Pattern: "("}, []string{"bad{}"}, nil, nil); err == nil {
+		t.Error("Expected an error for an invalid regular expression")
+	}
+	if rules := w.ListRules(); len(rules) != 2 {
+		t.Errorf("Expected the invalid rule not to be added, got %d rules", len(rules))
+	}
+}
@@ -1,52 +1,194 @@
 package webview
 
 import (
+	"fmt"
+	"maps"
+	"net/textproto"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 )
 
+// Rule customizes the pages its matcher matches
+type Rule struct {
+	// ID identifies the rule; InsertRule assigns one when empty
+	ID          string
+	Description string
+	// Priority orders the rules: rules are applied from the lowest priority
+	// to the highest, then in the order they were added, so CSS, JS and
+	// headers of a later rule take precedence over earlier ones
+	Priority int
+	// Disabled rules are kept but ignored
+	Disabled bool
+	// URL is the pattern of Match
+	URL     string
+	Match   *Matcher
+	CSS     []string
+	JS      []string
+	Headers map[string]string
+
+	seq int
+}
+
 // WebViewManager manages web content customization
 type WebViewManager struct {
 	mu    sync.RWMutex
-	rules []struct {
-		URL     string
-		CSS     []string
-		JS      []string
-		Headers map[string]string
-	}
+	rules []Rule
+	seq   int
 }
 
 // NewWebViewManager creates a new WebViewManager
 func NewWebViewManager() *WebViewManager {
 	return &WebViewManager{
-		rules: make([]struct {
-			URL     string
-			CSS     []string
-			JS      []string
-			Headers map[string]string
-		}, 0),
+		rules: make([]Rule, 0),
 	}
 }
 
-// AddRule adds a new injection rule
-func (w *WebViewManager) AddRule(url string, css []string, js []string, headers map[string]string) {
+// AddRule adds a new injection rule for the URLs containing url. It is the
+// legacy matching of MatchLegacy; AddMatchedRule selects precisely.
+func (w *WebViewManager) AddRule(url string, css []string, js []string, headers map[string]string) error {
+	_, err := w.InsertRule(Rule{URL: url, CSS: css, JS: js, Headers: headers})
+	return err
+}
+
+// AddMatchedRule adds a new injection rule for the URLs match matches. An
+// invalid matcher, such as a bad regular expression, is reported and the
+// rule is not added.
+func (w *WebViewManager) AddMatchedRule(match *Matcher, css []string, js []string, headers map[string]string) error {
+	_, err := w.InsertRule(Rule{Match: match, CSS: css, JS: js, Headers: headers})
+	return err
+}
+
+// InsertRule adds a rule and returns its ID. A rule without a matcher
+// matches URLs containing its URL, like AddRule. The matcher is validated
+// and, like the other fields, copied, so later changes do not affect the rule.
+func (w *WebViewManager) InsertRule(rule Rule) (string, error) {
+	rule, err := normalizeRule(rule)
+	if err != nil {
+		return "", err
+	}
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.rules = append(w.rules, struct {
-		URL     string
-		CSS     []string
-		JS      []string
-		Headers map[string]string
-	}{
-		URL:     url,
-		CSS:     css,
-		JS:      js,
-		Headers: headers,
+	w.seq++
+	if rule.ID == "" {
+		rule.ID = fmt.Sprintf("rule-%d", w.seq)
+		for w.find(rule.ID) >= 0 {
+			w.seq++
+			rule.ID = fmt.Sprintf("rule-%d", w.seq)
+		}
+	} else if w.find(rule.ID) >= 0 {
+		return "", fmt.Errorf("rule %q already exists", rule.ID)
+	}
+	rule.seq = w.seq
+	w.rules = append(w.rules, rule)
+	w.sortRules()
+	return rule.ID, nil
+}
+
+// UpdateRule replaces the rule with the ID of rule, which keeps its place
+// among rules of the same priority
+func (w *WebViewManager) UpdateRule(rule Rule) error {
+	rule, err := normalizeRule(rule)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.find(rule.ID)
+	if i < 0 {
+		return fmt.Errorf("rule %q not found", rule.ID)
+	}
+	rule.seq = w.rules[i].seq
+	w.rules[i] = rule
+	w.sortRules()
+	return nil
+}
+
+// RemoveRule removes the rule with the given ID and reports whether it
+// existed
+func (w *WebViewManager) RemoveRule(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.find(id)
+	if i < 0 {
+		return false
+	}
+	w.rules = append(w.rules[:i], w.rules[i+1:]...)
+	return true
+}
+
+// ListRules returns copies of the rules in the order they are applied
+func (w *WebViewManager) ListRules() []Rule {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	rules := make([]Rule, len(w.rules))
+	for i, rule := range w.rules {
+		rules[i] = copyRule(rule)
+	}
+	return rules
+}
+
+// find returns the index of the rule with the given ID, or -1
+func (w *WebViewManager) find(id string) int {
+	for i, rule := range w.rules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortRules orders the rules by priority, then by the order they were added
+func (w *WebViewManager) sortRules() {
+	sort.Slice(w.rules, func(i, j int) bool {
+		if w.rules[i].Priority != w.rules[j].Priority {
+			return w.rules[i].Priority < w.rules[j].Priority
+		}
+		return w.rules[i].seq < w.rules[j].seq
 	})
 }
 
-// GetRulesForURL returns all matching rules for a given URL
+// normalizeRule returns a copy of rule with a compiled matcher, a legacy one
+// for a rule without a matcher, and URL in sync with it
+func normalizeRule(rule Rule) (Rule, error) {
+	match := rule.Match
+	if match == nil {
+		match = &Matcher{Type: MatchLegacy, Pattern: rule.URL}
+	}
+	match, err := match.compile()
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid matcher: %w", err)
+	}
+	rule = copyRule(rule)
+	rule.Match = match
+	rule.URL = match.Pattern
+	return rule, nil
+}
+
+// copyRule returns a copy of rule that shares no slices, maps or matcher
+// with it
+func copyRule(rule Rule) Rule {
+	if rule.Match != nil {
+		match := *rule.Match
+		match.Query = maps.Clone(match.Query)
+		rule.Match = &match
+	}
+	rule.CSS = slices.Clone(rule.CSS)
+	rule.JS = slices.Clone(rule.JS)
+	rule.Headers = maps.Clone(rule.Headers)
+	return rule
+}
+
+// GetRulesForURL returns the CSS, JS and headers of the enabled rules
+// matching a given URL, in the order of the rules. Header names are compared
+// case-insensitively and the last rule setting a header wins.
 func (w *WebViewManager) GetRulesForURL(url string) ([]string, []string, map[string]string) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -56,11 +198,11 @@ func (w *WebViewManager) GetRulesForURL(url string) ([]string, []string, map[str
 	headers := make(map[string]string)
 
 	for _, rule := range w.rules {
-		if strings.Contains(url, rule.URL) {
+		if !rule.Disabled && rule.Match.Match(url) {
 			css = append(css, rule.CSS...)
 			js = append(js, rule.JS...)
 			for k, v := range rule.Headers {
-				headers[k] = v
+				headers[textproto.CanonicalMIMEHeaderKey(k)] = v
 			}
 		}
 	}
@@ -73,12 +215,7 @@ func (w *WebViewManager) ClearRules() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.rules = make([]struct {
-		URL     string
-		CSS     []string
-		JS      []string
-		Headers map[string]string
-	}, 0)
+	w.rules = make([]Rule, 0)
 }
 
 // GenerateInjectionScript generates the JavaScript code for injecting CSS and JS
@@ -0,0 +1,131 @@
+package webview
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleOrder(t *testing.T) {
+	w := NewWebViewManager()
+	w.AddRule("example.com", []string{"first{}"}, nil, map[string]string{"X-Theme": "first"})
+	high, err := w.InsertRule(Rule{
+		ID:       "high",
+		Priority: 10,
+		URL:      "example.com",
+		CSS:      []string{"high{}"},
+		Headers:  map[string]string{"x-theme": "high"},
+	})
+	if err != nil || high != "high" {
+		t.Fatalf("Failed to insert rule: %q %v", high, err)
+	}
+	w.AddRule("example.com", []string{"second{}"}, nil, map[string]string{"X-Theme": "second"})
+
+	css, _, headers := w.GetRulesForURL("https://example.com/")
+	if want := []string{"first{}", "second{}", "high{}"}; !reflect.DeepEqual(css, want) {
+		t.Errorf("Expected CSS %v, got %v", want, css)
+	}
+	if len(headers) != 1 || headers["X-Theme"] != "high" {
+		t.Errorf("Expected the header of the highest priority, got %v", headers)
+	}
+
+	var ids []string
+	for _, rule := range w.ListRules() {
+		ids = append(ids, rule.ID)
+	}
+	if want := []string{"rule-1", "rule-3", "high"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Expected rules %v, got %v", want, ids)
+	}
+
+	if _, err := w.InsertRule(Rule{ID: "high"}); err == nil {
+		t.Errorf("Expected an error for a duplicate ID")
+	}
+}
+
+func TestUpdateAndRemoveRule(t *testing.T) {
+	w := NewWebViewManager()
+	w.AddRule("example.com", []string{"a{}"}, nil, nil)
+	w.AddRule("example.com", []string{"b{}"}, nil, nil)
+
+	rules := w.ListRules()
+	rules[0].Disabled = true
+	if err := w.UpdateRule(rules[0]); err != nil {
+		t.Fatalf("Failed to update rule: %v", err)
+	}
+	if css, _, _ := w.GetRulesForURL("https://example.com/"); !reflect.DeepEqual(css, []string{"b{}"}) {
+		t.Errorf("Expected the disabled rule to be skipped, got %v", css)
+	}
+
+	// Equal priorities keep the order the rules were added in
+	rules[0].Disabled = false
+	rules[1].Priority = -1
+	w.UpdateRule(rules[1])
+	w.UpdateRule(rules[0])
+	rules[1].Priority = 0
+	w.UpdateRule(rules[1])
+	if css, _, _ := w.GetRulesForURL("https://example.com/"); !reflect.DeepEqual(css, []string{"a{}", "b{}"}) {
+		t.Errorf("Expected the insertion order, got %v", css)
+	}
+
+	if err := w.UpdateRule(Rule{ID: "missing"}); err == nil {
+		t.Errorf("Expected an error updating a missing rule")
+	}
+	if !w.RemoveRule(rules[0].ID) || w.RemoveRule(rules[0].ID) {
+		t.Errorf("Expected the rule to be removed once")
+	}
+	if rules := w.ListRules(); len(rules) != 1 || rules[0].ID != "rule-2" {
+		t.Errorf("Expected one rule left, got %v", rules)
+	}
+}
+
+func TestRuleMatchers(t *testing.T) {
+	w := NewWebViewManager()
+	if _, err := w.InsertRule(Rule{Match: &Matcher{Type: MatchGlob, Pattern: "*.example.com/app/*"}, CSS: []string{"glob{}"}}); err != nil {
+		t.Fatalf("Failed to insert a glob literal: %v", err)
+	}
+	if _, err := w.InsertRule(Rule{Match: &Matcher{Type: MatchURL, Host: "example.com", Path: "/doc"}, CSS: []string{"url{}"}}); err != nil {
+		t.Fatalf("Failed to insert a url literal: %v", err)
+	}
+	invalid := []*Matcher{
+		{},
+		{Type: MatchRegex, Pattern: "("},
+		{Type: MatchGlob},
+		{Type: MatchURL, Host: "example.com/app"},
+	}
+	for _, m := range invalid {
+		if _, err := w.InsertRule(Rule{Match: m, CSS: []string{"invalid{}"}}); err == nil {
+			t.Errorf("Expected an error for matcher %+v", m)
+		}
+	}
+
+	if css, _, _ := w.GetRulesForURL("https://www.example.com/app/x"); !reflect.DeepEqual(css, []string{"glob{}"}) {
+		t.Errorf("Expected only the glob rule, got %v", css)
+	}
+	if css, _, _ := w.GetRulesForURL("https://example.com/doc"); !reflect.DeepEqual(css, []string{"url{}"}) {
+		t.Errorf("Expected only the url rule, got %v", css)
+	}
+
+	// Matchers that were not compiled match nothing
+	for _, m := range append(invalid, &Matcher{Type: MatchRegex}, &Matcher{Type: MatchGlob, Pattern: "example.com"}) {
+		if m.Match("https://example.com/") {
+			t.Errorf("Expected matcher %+v to match nothing", m)
+		}
+	}
+}
+
+func TestListRulesCopies(t *testing.T) {
+	w := NewWebViewManager()
+	m, _ := NewURLMatcher("example.com", "", map[string]string{"tab": "edit"})
+	w.AddMatchedRule(m, []string{"a{}"}, []string{"a()"}, map[string]string{"X-A": "1"})
+
+	rules := w.ListRules()
+	rules[0].CSS[0] = "changed{}"
+	rules[0].JS[0] = "changed()"
+	rules[0].Headers["X-A"] = "changed"
+	rules[0].Match.Query["tab"] = "view"
+	m.Query["tab"] = "view"
+
+	css, js, headers := w.GetRulesForURL("https://example.com/?tab=edit")
+	if !reflect.DeepEqual(css, []string{"a{}"}) || !reflect.DeepEqual(js, []string{"a()"}) || headers["X-A"] != "1" {
+		t.Errorf("Expected the rule to be unchanged, got %v %v %v", css, js, headers)
+	}
+}